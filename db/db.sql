@@ -12,6 +12,15 @@ create table "user"
 create index index_users_nickname_hash ON "user" USING HASH ("nickname");
 create index index_users_email_hash ON "user" USING HASH ("email");
 create index index_users_id ON "user" USING HASH ("id");
+create index index_users_fullname_fts ON "user" USING GIN (to_tsvector('simple', "fullname"));
+
+create table "user_alias"
+(
+    "nickname" citext COLLATE "ucs_basic" not null primary key,
+    "current"  citext COLLATE "ucs_basic" not null
+);
+
+create index index_user_alias_current ON "user_alias" ("current");
 
 
 create table "forum"
@@ -21,13 +30,17 @@ create table "forum"
     "title"   text          not null,
     "user"    citext        not null,
     "posts"   int default 0 not null,
-    "threads" int default 0 not null
+    "threads" int default 0 not null,
+    "created" timestamptz default now() not null,
+    "isArchived" bool default false not null
 );
 
 create index index_forums ON "forum" ("slug");
 create index index_forums_slug_hash ON "forum" USING HASH ("slug");
 create index index_forums_users_foreign ON "forum" USING HASH ("user");
 create index index_forums_id_hash ON "forum" USING HASH ("id");
+create index index_forums_created ON "forum" ("created", "slug");
+create index index_forums_title ON "forum" ("title", "slug");
 
 create table "thread"
 (
@@ -38,7 +51,14 @@ create table "thread"
     "forum"   text          not null,
     "message" text          not null,
     "votes"   int default 0 not null,
-    "created" timestamptz   not null
+    "created" timestamptz   not null,
+    "isDeleted" bool default false not null,
+    "last_post_at" timestamptz,
+    "last_post_author" text,
+    "posts" int default 0 not null,
+    "isLocked" bool default false not null,
+    "isPinned" bool default false not null,
+    "isArchived" bool default false not null
 );
 
 create index index_threads_forum_created ON "thread" ("forum", "id");
@@ -46,6 +66,12 @@ create index index_threads_forum_ID ON "thread" ("forum", "created");
 create index index_threads_created ON "thread" ("created");
 create index index_threads_slug_hash ON "thread" USING HASH ("slug");
 create index index_threads_id_hash ON "thread" USING HASH ("id");
+create index index_threads_author_created ON "thread" (("author"::citext), "created");
+create index index_threads_author ON "thread" ("author");
+create index index_threads_last_post_author ON "thread" ("last_post_author");
+create index index_threads_forum_votes ON "thread" ("forum", "votes", "id");
+create index index_threads_forum_active ON "thread" ("forum", (coalesce("last_post_at", "created")), "id");
+create index index_threads_fts ON "thread" USING GIN (to_tsvector('simple', "title" || ' ' || "message"));
 
 create function inc_forum_thread() returns trigger as
 $$
@@ -64,21 +90,40 @@ execute procedure inc_forum_thread();
 
 create table "post"
 (
-    "id"       serial primary key,
-    "parent"   int         not null,
-    "path"     text        not null default '',
+    "id"       bigserial primary key,
+    "parent"   bigint      not null,
+    "path"     bigint[]    not null default '{}',
     "author"   text        not null,
     "forum"    text        not null,
     "thread"   int         not null,
     "message"  text        not null,
     "isEdited" bool        not null default false,
+    "isDeleted" bool       not null default false,
     "created"  timestamptz not null
 );
 
+-- post ids are reserved by the application in blocks of the sequence increment
+alter sequence post_id_seq increment by 100;
+
 create index index_posts_id on "post" USING HASH ("id");
 create index index_posts_thread_id on "post" ("thread", "id");
 create index index_posts_thread_parent_path on "post" ("thread", "parent", "path");
-create index on "post" (substring("path",1,7));
+create index index_posts_thread_path on "post" ("thread", "path");
+create index index_posts_root_path on "post" (("path"[1]), "path");
+create index index_posts_author_created on "post" (("author"::citext), "created", "id");
+create index index_posts_author on "post" ("author");
+create index index_posts_fts on "post" USING GIN (to_tsvector('simple', "message"));
+
+create table "post_revision"
+(
+    "post"     bigint      not null,
+    "revision" int         not null,
+    "message"  text        not null,
+    "editor"   text        not null default '',
+    "created"  timestamptz not null,
+    primary key ("post", "revision")
+);
+create index index_post_revisions_editor on "post_revision" ("editor");
 
 create table "vote"
 (
@@ -89,6 +134,7 @@ create table "vote"
 );
 create index on "vote" ("thread", "nickname");
 create index on "vote" ("nickname", "thread", "voice");
+create index index_votes_nickname_id on "vote" (("nickname"::citext), "id");
 
 create table "forum_user"
 (
@@ -0,0 +1,3 @@
+-- post ids used to come from an in-memory counter, so post_id_seq was never advanced.
+alter sequence post_id_seq increment by 100;
+select setval('post_id_seq', coalesce((select max(id) from post), 0) + 1, false);
@@ -0,0 +1,14 @@
+-- fixed-width '0000001.0000002.0000000...' paths become bigint[] paths: {1,2}.
+drop index if exists post_substring_idx;
+
+alter table post alter column id type bigint;
+alter sequence post_id_seq as bigint;
+alter table post alter column parent type bigint;
+
+alter table post alter column path drop default;
+alter table post alter column path type bigint[]
+    using array_remove(string_to_array(path, '.')::bigint[], 0);
+alter table post alter column path set default '{}';
+
+create index index_posts_thread_path on "post" ("thread", "path");
+create index index_posts_root_path on "post" (("path"[1]), "path");
@@ -0,0 +1,9 @@
+create table "post_revision"
+(
+    "post"     bigint      not null,
+    "revision" int         not null,
+    "message"  text        not null,
+    "editor"   text        not null default '',
+    "created"  timestamptz not null,
+    primary key ("post", "revision")
+);
@@ -0,0 +1 @@
+alter table post add column "isDeleted" bool not null default false;
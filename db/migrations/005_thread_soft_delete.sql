@@ -0,0 +1 @@
+alter table thread add column "isDeleted" bool default false not null;
@@ -0,0 +1,7 @@
+create table "user_alias"
+(
+    "nickname" citext COLLATE "ucs_basic" not null primary key,
+    "current"  citext COLLATE "ucs_basic" not null
+);
+
+create index index_user_alias_current ON "user_alias" ("current");
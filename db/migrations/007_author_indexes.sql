@@ -0,0 +1,3 @@
+create index index_threads_author_created ON "thread" (("author"::citext), "created");
+create index index_posts_author_created on "post" (("author"::citext), "created", "id");
+create index index_votes_nickname_id on "vote" (("nickname"::citext), "id");
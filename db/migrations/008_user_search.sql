@@ -0,0 +1 @@
+create index index_users_fullname_fts ON "user" USING GIN (to_tsvector('simple', "fullname"));
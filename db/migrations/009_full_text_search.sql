@@ -0,0 +1,3 @@
+-- expression indexes are maintained by postgres on every insert and update of post and thread.
+create index index_threads_fts ON "thread" USING GIN (to_tsvector('simple', "title" || ' ' || "message"));
+create index index_posts_fts on "post" USING GIN (to_tsvector('simple', "message"));
@@ -0,0 +1,4 @@
+alter table forum add column "created" timestamptz default now() not null;
+
+create index index_forums_created ON "forum" ("created", "slug");
+create index index_forums_title ON "forum" ("title", "slug");
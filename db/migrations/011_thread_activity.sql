@@ -0,0 +1,5 @@
+alter table thread add column "last_post_at" timestamptz;
+update thread set last_post_at = (select max(created) from post where post.thread = thread.id);
+
+create index index_threads_forum_votes ON "thread" ("forum", "votes", "id");
+create index index_threads_forum_active ON "thread" ("forum", (coalesce("last_post_at", "created")), "id");
@@ -0,0 +1,8 @@
+alter table thread add column "last_post_author" text;
+alter table thread add column "posts" int default 0 not null;
+
+update thread set (posts, last_post_at, last_post_author) = (
+    select count(*), max(created),
+        (select author from post where thread = thread.id and not "isDeleted" order by created desc, id desc limit 1)
+    from post where thread = thread.id and not "isDeleted"
+);
@@ -0,0 +1,3 @@
+alter table thread add column "isLocked" bool default false not null;
+alter table thread add column "isPinned" bool default false not null;
+alter table thread add column "isArchived" bool default false not null;
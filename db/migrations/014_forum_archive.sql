@@ -0,0 +1 @@
+alter table forum add column "isArchived" bool default false not null;
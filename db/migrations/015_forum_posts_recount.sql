@@ -0,0 +1,5 @@
+update forum set posts = (
+    select count(*) from post
+        join thread on thread.id = post.thread
+    where post.forum = forum.slug and not post."isDeleted" and not thread."isDeleted"
+);
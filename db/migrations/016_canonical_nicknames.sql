@@ -0,0 +1,23 @@
+-- nicknames are stored exactly as the user row spells them, so the rename
+-- and delete cascades can compare text columns without casting to citext
+update thread set author = "user".nickname from "user"
+    where thread.author::citext = "user".nickname and thread.author <> "user".nickname;
+update thread set last_post_author = "user".nickname from "user"
+    where thread.last_post_author::citext = "user".nickname and thread.last_post_author <> "user".nickname;
+update post set author = "user".nickname from "user"
+    where post.author::citext = "user".nickname and post.author <> "user".nickname;
+update post_revision set editor = "user".nickname from "user"
+    where post_revision.editor::citext = "user".nickname and post_revision.editor <> "user".nickname;
+update vote set nickname = "user".nickname from "user"
+    where vote.nickname::citext = "user".nickname and vote.nickname <> "user".nickname;
+delete from forum_user using "user"
+    where forum_user."user"::citext = "user".nickname and forum_user."user" <> "user".nickname
+    and exists (select 1 from forum_user canonical
+        where canonical.forum = forum_user.forum and canonical."user" = "user".nickname);
+update forum_user set "user" = "user".nickname from "user"
+    where forum_user."user"::citext = "user".nickname and forum_user."user" <> "user".nickname;
+
+create index index_threads_author on "thread" ("author");
+create index index_threads_last_post_author on "thread" ("last_post_author");
+create index index_posts_author on "post" ("author");
+create index index_post_revisions_editor on "post_revision" ("editor");
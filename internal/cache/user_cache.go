@@ -67,3 +67,13 @@ func (u *UserCache) addID(id int, nick string) {
 	u.nickByID[id] = nick
 	u.nickByIDMutex.Unlock()
 }
+
+func (u *UserCache) Remove(id int, nick string) {
+	u.idByNickMutex.Lock()
+	delete(u.idByNick, strings.ToLower(nick))
+	u.idByNickMutex.Unlock()
+
+	u.nickByIDMutex.Lock()
+	delete(u.nickByID, id)
+	u.nickByIDMutex.Unlock()
+}
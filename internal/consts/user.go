@@ -0,0 +1,4 @@
+package consts
+
+// DeletedUserNickname replaces the author of content left by deleted accounts.
+const DeletedUserNickname = "[deleted]"
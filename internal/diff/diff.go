@@ -0,0 +1,100 @@
+package diff
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+const (
+	OpEqual  = "equal"
+	OpInsert = "insert"
+	OpDelete = "delete"
+
+	// maxCells bounds the LCS table built for the part of two texts that differs.
+	maxCells = 1 << 20
+)
+
+// ErrTooLarge is returned when the changed parts of two texts are too long to diff.
+var ErrTooLarge = errors.New("texts differ in too many words to diff")
+
+type Change struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+var wordPattern = regexp.MustCompile(`\S+\s*|\s+`)
+
+// Words diffs two texts word by word. Whitespace stays attached to the preceding
+// word, so joining the equal and insert chunks gives back b.
+// The common start and end are matched without the LCS table, so only the
+// changed middle counts against its size limit.
+func Words(a, b string) ([]Change, error) {
+	aWords, bWords := wordPattern.FindAllString(a, -1), wordPattern.FindAllString(b, -1)
+	prefix := 0
+	for prefix < len(aWords) && prefix < len(bWords) && aWords[prefix] == bWords[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(aWords)-prefix && suffix < len(bWords)-prefix &&
+		aWords[len(aWords)-1-suffix] == bWords[len(bWords)-1-suffix] {
+		suffix++
+	}
+	aMiddle, bMiddle := aWords[prefix:len(aWords)-suffix], bWords[prefix:len(bWords)-suffix]
+	if (len(aMiddle)+1)*(len(bMiddle)+1) > maxCells {
+		return nil, ErrTooLarge
+	}
+	changes := make([]Change, 0)
+	if prefix > 0 {
+		changes = append(changes, Change{Op: OpEqual, Text: strings.Join(aWords[:prefix], "")})
+	}
+	for _, change := range diff(aMiddle, bMiddle) {
+		changes = appendChange(changes, change.Op, change.Text)
+	}
+	if suffix > 0 {
+		changes = appendChange(changes, OpEqual, strings.Join(aWords[len(aWords)-suffix:], ""))
+	}
+	return changes, nil
+}
+
+func diff(a, b []string) []Change {
+	width := len(b) + 1
+	lcs := make([]int32, (len(a)+1)*width)
+	at := func(i, j int) int32 { return lcs[i*width+j] }
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i*width+j] = at(i+1, j+1) + 1
+			} else if at(i+1, j) >= at(i, j+1) {
+				lcs[i*width+j] = at(i+1, j)
+			} else {
+				lcs[i*width+j] = at(i, j+1)
+			}
+		}
+	}
+	changes := make([]Change, 0)
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			changes = appendChange(changes, OpEqual, a[i])
+			i++
+			j++
+		case i < len(a) && (j == len(b) || at(i+1, j) >= at(i, j+1)):
+			changes = appendChange(changes, OpDelete, a[i])
+			i++
+		default:
+			changes = appendChange(changes, OpInsert, b[j])
+			j++
+		}
+	}
+	return changes
+}
+
+func appendChange(changes []Change, op, text string) []Change {
+	if last := len(changes) - 1; last >= 0 && changes[last].Op == op {
+		changes[last].Text += text
+		return changes
+	}
+	return append(changes, Change{Op: op, Text: text})
+}
@@ -0,0 +1,114 @@
+package diff
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWords(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want []Change
+	}{
+		{
+			name: "identical",
+			a:    "hello world",
+			b:    "hello world",
+			want: []Change{{OpEqual, "hello world"}},
+		},
+		{
+			name: "both empty",
+			a:    "",
+			b:    "",
+			want: []Change{},
+		},
+		{
+			name: "from empty",
+			a:    "",
+			b:    "new text",
+			want: []Change{{OpInsert, "new text"}},
+		},
+		{
+			name: "to empty",
+			a:    "old text",
+			b:    "",
+			want: []Change{{OpDelete, "old text"}},
+		},
+		{
+			name: "insert",
+			a:    "hello world",
+			b:    "hello brave world",
+			want: []Change{{OpEqual, "hello "}, {OpInsert, "brave "}, {OpEqual, "world"}},
+		},
+		{
+			name: "delete",
+			a:    "hello brave world",
+			b:    "hello world",
+			want: []Change{{OpEqual, "hello "}, {OpDelete, "brave "}, {OpEqual, "world"}},
+		},
+		{
+			name: "replace",
+			a:    "the quick fox",
+			b:    "the slow fox",
+			want: []Change{{OpEqual, "the "}, {OpDelete, "quick "}, {OpInsert, "slow "}, {OpEqual, "fox"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Words(tt.a, tt.b)
+			if err != nil {
+				t.Fatalf("Words(%q, %q) error = %v", tt.a, tt.b, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Words(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+			var a, b string
+			for _, change := range got {
+				if change.Op != OpInsert {
+					a += change.Text
+				}
+				if change.Op != OpDelete {
+					b += change.Text
+				}
+			}
+			if a != tt.a || b != tt.b {
+				t.Errorf("Words(%q, %q) does not rebuild its inputs: %q, %q", tt.a, tt.b, a, b)
+			}
+		})
+	}
+}
+
+func TestWordsLarge(t *testing.T) {
+	words := func(prefix string, n int) string {
+		var text strings.Builder
+		for i := 0; i < n; i++ {
+			text.WriteString(prefix)
+			text.WriteString(strings.Repeat("x", i%7))
+			text.WriteString(" ")
+		}
+		return text.String()
+	}
+	long := words("a", 50000)
+
+	// A small edit in a long text only diffs the changed middle.
+	middle := len(words("a", 25000))
+	edited := long[:middle] + "inserted " + long[middle:]
+	got, err := Words(long, edited)
+	if err != nil {
+		t.Fatalf("Words(long, edited) error = %v", err)
+	}
+	if len(got) != 3 || got[1] != (Change{OpInsert, "inserted "}) {
+		t.Errorf("Words(long, edited) = %d changes, want equal, insert, equal", len(got))
+	}
+	if got, err := Words(long, long); err != nil || len(got) != 1 {
+		t.Errorf("Words(long, long) = %d changes, %v, want one equal change", len(got), err)
+	}
+
+	// Rewriting a long text entirely is refused instead of building a huge table.
+	if _, err := Words(long, words("b", 50000)); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Words of two unrelated long texts error = %v, want %v", err, ErrTooLarge)
+	}
+}
@@ -1,19 +1,56 @@
 package generator
 
-import "sync/atomic"
+import (
+	"sync"
 
+	"github.com/jmoiron/sqlx"
+)
+
+// Generator hands out ids from blocks reserved in a postgres sequence.
+// The block size is the sequence increment, so every nextval reserves
+// [value, value+increment) for this process only.
 type Generator struct {
-	current int32
+	db       *sqlx.DB
+	sequence string
+
+	mutex   sync.Mutex
+	current int
+	limit   int
 }
 
-func NewGenerator() Generator {
-	return Generator{}
+func NewGenerator(db *sqlx.DB, sequence string) *Generator {
+	return &Generator{db: db, sequence: sequence}
 }
 
-func (s *Generator) Next(count int) []int {
+func (g *Generator) Next(count int) ([]int, error) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
 	result := make([]int, 0, count)
-	for i := 0; i < count; i++ {
-		result = append(result, int(atomic.AddInt32(&s.current, 1)))
+	for len(result) < count {
+		if g.current >= g.limit {
+			if err := g.reserve(); err != nil {
+				return nil, err
+			}
+		}
+		result = append(result, g.current)
+		g.current++
+	}
+	return result, nil
+}
+
+func (g *Generator) reserve() error {
+	block := struct {
+		Start     int `db:"start"`
+		Increment int `db:"increment"`
+	}{}
+	err := g.db.Get(&block,
+		`select nextval(seqrelid) as start, seqincrement as increment from pg_sequence where seqrelid = $1::regclass`,
+		g.sequence,
+	)
+	if err != nil {
+		return err
 	}
-	return result
+	g.current = block.Start
+	g.limit = block.Start + block.Increment
+	return nil
 }
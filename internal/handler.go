@@ -3,6 +3,7 @@ package internal
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/buaazp/fasthttprouter"
 	"github.com/labstack/echo/v4"
 	"io/ioutil"
@@ -13,6 +14,12 @@ import (
 	"strings"
 )
 
+const (
+	formatNested = "nested"
+
+	defaultContextCount = 10
+)
+
 type Handler struct {
 	usecase *Usecase
 	router  *fasthttprouter.Router
@@ -26,9 +33,19 @@ func NewHandler(usecase Usecase, echo *echo.Echo) {
 	echo.POST("/api/user/:nickname/create", h.handleUserCreate())
 	echo.GET("/api/user/:nickname/profile", h.handleGetUserProfile())
 	echo.POST("/api/user/:nickname/profile", h.handleUserUpdate())
+	echo.POST("/api/user/:nickname/rename", h.handleUserRename())
+	echo.DELETE("/api/user/:nickname", h.handleUserDelete())
+	echo.GET("/api/user/:nickname/export", h.handleUserExport())
+	echo.GET("/api/user/:nickname/threads", h.handleGetUserThreads())
+	echo.GET("/api/user/:nickname/posts", h.handleGetUserPosts())
+	echo.GET("/api/user/:nickname/votes", h.handleGetUserVotes())
+	echo.GET("/api/users/search", h.handleSearchUsers())
 	echo.POST("/api/forum/create", h.handleForumCreate())
+	echo.GET("/api/forums", h.handleGetForums())
 	echo.POST("/api/forum/:slug/create", h.handleThreadCreate())
 	echo.GET("/api/forum/:slug/details", h.handleGetForumDetails())
+	echo.POST("/api/forum/:slug/details", h.handleForumUpdate())
+	echo.DELETE("/api/forum/:slug", h.handleForumDelete())
 	echo.GET("/api/forum/:slug/threads", h.handleGetForumThreads())
 	echo.GET("/api/forum/:slug/users", h.handleGetForumUsers())
 	echo.POST("/api/thread/:slug_or_id/create", h.handlePostCreate())
@@ -36,8 +53,23 @@ func NewHandler(usecase Usecase, echo *echo.Echo) {
 	echo.GET("/api/thread/:slug_or_id/details", h.handleGetThreadDetails())
 	echo.POST("/api/thread/:slug_or_id/details", h.handleThreadUpdate())
 	echo.GET("/api/thread/:slug_or_id/posts", h.handleGetThreadPosts())
+	echo.GET("/api/thread/:slug_or_id/participants", h.handleGetThreadParticipants())
+	echo.POST("/api/thread/:slug_or_id/move", h.handleThreadMove())
+	echo.POST("/api/thread/:slug_or_id/merge", h.handleThreadMerge())
+	echo.POST("/api/thread/:slug_or_id/moderate", h.handleThreadModerate())
+	echo.DELETE("/api/thread/:slug_or_id", h.handleThreadDelete())
+	echo.POST("/api/thread/:slug_or_id/restore", h.handleThreadRestore())
 	echo.GET("/api/post/:id/details", h.handleGetPostDetails())
 	echo.POST("/api/post/:id/details", h.handlePostUpdate())
+	echo.DELETE("/api/post/:id", h.handlePostDelete())
+	echo.POST("/api/post/:id/split", h.handlePostSplit())
+	echo.GET("/api/post/:id/replies", h.handleGetPostReplies())
+	echo.GET("/api/post/:id/ancestors", h.handleGetPostAncestors())
+	echo.GET("/api/post/:id/context", h.handleGetPostContext())
+	echo.GET("/api/post/:id/revisions", h.handleGetPostRevisions())
+	echo.GET("/api/post/:id/revisions/:revision", h.handleGetPostRevision())
+	echo.GET("/api/post/:id/diff", h.handleGetPostDiff())
+	echo.GET("/api/search", h.handleSearch())
 	echo.GET("/api/service/status", h.handleStatus())
 	echo.POST("/api/service/clear", h.handleClear())
 
@@ -105,6 +137,134 @@ func (h *Handler) handleUserUpdate() echo.HandlerFunc {
 	}
 }
 
+func (h *Handler) handleUserRename() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		u := model.UserRename{}
+		body, err := ioutil.ReadAll(c.Request().Body)
+		if err != nil {
+			return Error(c, err)
+		}
+		if err := json.Unmarshal(body, &u); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"message": err.Error(),
+			})
+		}
+		user, err := h.usecase.renameUser(c.Param("nickname"), u.Nickname)
+		if err != nil {
+			return Error(c, err)
+		}
+		return c.JSON(http.StatusOK, user)
+	}
+}
+
+func (h *Handler) handleUserDelete() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if err := h.usecase.deleteUser(c.Param("nickname")); err != nil {
+			return Error(c, err)
+		}
+		return c.NoContent(http.StatusNoContent)
+	}
+}
+
+// handleUserExport sends the status with the first record, so a user that does not exist still
+// gets a plain 404. An error after that can only be reported in the stream: it ends with an
+// error record, which a complete export never has.
+func (h *Handler) handleUserExport() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		response := c.Response()
+		encoder := json.NewEncoder(response)
+		err := h.usecase.exportUser(c.Param("nickname"), func(record model.ExportRecord) error {
+			if !response.Committed {
+				response.Header().Set(echo.HeaderContentType, "application/x-ndjson")
+				response.Header().Set(echo.HeaderContentDisposition, `attachment; filename="export.jsonl"`)
+				response.WriteHeader(http.StatusOK)
+			}
+			if err := encoder.Encode(record); err != nil {
+				return err
+			}
+			response.Flush()
+			return nil
+		})
+		if err == nil {
+			return nil
+		}
+		if !response.Committed {
+			return Error(c, err)
+		}
+		return encoder.Encode(model.ExportRecord{Type: "error", Data: map[string]string{"message": err.Error()}})
+	}
+}
+
+func (h *Handler) handleGetUserThreads() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		limit, _ := strconv.Atoi(c.QueryParam("limit"))
+		desc, _ := strconv.ParseBool(c.QueryParam("desc"))
+		threads, page, err := h.usecase.getUserThreads(
+			c.Param("nickname"),
+			c.QueryParam("since"),
+			c.QueryParam("cursor"),
+			limit,
+			desc,
+		)
+		if err != nil {
+			return Error(c, err)
+		}
+		setPageLinks(c, page)
+		return c.JSON(http.StatusOK, threads)
+	}
+}
+
+func (h *Handler) handleGetUserPosts() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		limit, _ := strconv.Atoi(c.QueryParam("limit"))
+		desc, _ := strconv.ParseBool(c.QueryParam("desc"))
+		posts, page, err := h.usecase.getUserPosts(
+			c.Param("nickname"),
+			c.QueryParam("since"),
+			c.QueryParam("cursor"),
+			limit,
+			desc,
+		)
+		if err != nil {
+			return Error(c, err)
+		}
+		setPageLinks(c, page)
+		return c.JSON(http.StatusOK, posts)
+	}
+}
+
+func (h *Handler) handleGetUserVotes() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		sp := c.QueryParam("since")
+		var since *int = nil
+		if sp != "" {
+			n, _ := strconv.Atoi(sp)
+			since = &n
+		}
+		limit, _ := strconv.Atoi(c.QueryParam("limit"))
+		desc, _ := strconv.ParseBool(c.QueryParam("desc"))
+		votes, page, err := h.usecase.getUserVotes(c.Param("nickname"), since, c.QueryParam("cursor"), limit, desc)
+		if err != nil {
+			return Error(c, err)
+		}
+		setPageLinks(c, page)
+		return c.JSON(http.StatusOK, votes)
+	}
+}
+
+func (h *Handler) handleSearchUsers() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		limit, _ := strconv.Atoi(c.QueryParam("limit"))
+		desc, _ := strconv.ParseBool(c.QueryParam("desc"))
+		users, page, err := h.usecase.searchUsers(c.QueryParam("q"), c.QueryParam("since"), c.QueryParam("cursor"), limit, desc)
+		if err != nil {
+			return Error(c, err)
+		}
+		setPageLinks(c, page)
+		return c.JSON(http.StatusOK, users)
+	}
+}
+
 func (h *Handler) handleForumCreate() echo.HandlerFunc {
 	return func(c echo.Context) error {
 		forumToCreate := model.ForumCreate{}
@@ -161,14 +321,71 @@ func (h *Handler) handleGetForumDetails() echo.HandlerFunc {
 	}
 }
 
+func (h *Handler) handleForumUpdate() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		f := model.ForumUpdate{}
+		body, err := ioutil.ReadAll(c.Request().Body)
+		if err := json.Unmarshal(body, &f); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"message": err.Error(),
+			})
+		}
+		forum, err := h.usecase.updateForum(c.Param("slug"), f)
+		if err != nil {
+			return Error(c, err)
+		}
+		return c.JSON(http.StatusOK, forum)
+	}
+}
+
+func (h *Handler) handleForumDelete() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if err := h.usecase.deleteForum(c.Param("slug")); err != nil {
+			return Error(c, err)
+		}
+		return c.NoContent(http.StatusNoContent)
+	}
+}
+
+func (h *Handler) handleGetForums() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		limit, _ := strconv.Atoi(c.QueryParam("limit"))
+		desc, _ := strconv.ParseBool(c.QueryParam("desc"))
+		forums, page, err := h.usecase.getForums(
+			c.QueryParam("user"),
+			c.QueryParam("sort"),
+			c.QueryParam("since"),
+			c.QueryParam("cursor"),
+			limit,
+			desc,
+		)
+		if err != nil {
+			return Error(c, err)
+		}
+		setPageLinks(c, page)
+		return c.JSON(http.StatusOK, forums)
+	}
+}
+
 func (h *Handler) handleGetForumThreads() echo.HandlerFunc {
 	return func(c echo.Context) error {
 		limit, _ := strconv.Atoi(c.QueryParam("limit"))
 		desc, _ := strconv.ParseBool(c.QueryParam("desc"))
-		threads, err := h.usecase.getForumThreads(c.Param("slug"), c.QueryParam("since"), limit, desc)
+		archived, _ := strconv.ParseBool(c.QueryParam("archived"))
+		threads, page, err := h.usecase.getForumThreads(
+			c.Param("slug"),
+			c.QueryParam("since"),
+			c.QueryParam("cursor"),
+			c.QueryParam("sort"),
+			c.QueryParam("window"),
+			archived,
+			limit,
+			desc,
+		)
 		if err != nil {
 			return Error(c, err)
 		}
+		setPageLinks(c, page)
 		return c.JSON(http.StatusOK, threads)
 	}
 }
@@ -177,10 +394,11 @@ func (h *Handler) handleGetForumUsers() echo.HandlerFunc {
 	return func(c echo.Context) error {
 		limit, _ := strconv.Atoi(c.QueryParam("limit"))
 		desc, _ := strconv.ParseBool(c.QueryParam("desc"))
-		users, err := h.usecase.getForumUsers(c.Param("slug"), c.QueryParam("since"), limit, desc)
+		users, page, err := h.usecase.getForumUsers(c.Param("slug"), c.QueryParam("since"), c.QueryParam("cursor"), limit, desc)
 		if err != nil {
 			return Error(c, err)
 		}
+		setPageLinks(c, page)
 		return c.JSON(http.StatusOK, users)
 	}
 }
@@ -248,6 +466,84 @@ func (h *Handler) handleThreadUpdate() echo.HandlerFunc {
 	}
 }
 
+func (h *Handler) handleThreadMove() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		t := model.ThreadMove{}
+		body, err := ioutil.ReadAll(c.Request().Body)
+		if err := json.Unmarshal(body, &t); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"message": err.Error(),
+			})
+		}
+		thread, err := h.usecase.moveThread(c.Param("slug_or_id"), t.Forum)
+		if err != nil {
+			return Error(c, err)
+		}
+		return c.JSON(http.StatusOK, thread)
+	}
+}
+
+func (h *Handler) handleThreadMerge() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		t := model.ThreadMerge{}
+		body, err := ioutil.ReadAll(c.Request().Body)
+		if err := json.Unmarshal(body, &t); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"message": err.Error(),
+			})
+		}
+		thread, err := h.usecase.mergeThreads(c.Param("slug_or_id"), t.Target)
+		if err != nil {
+			return Error(c, err)
+		}
+		return c.JSON(http.StatusOK, thread)
+	}
+}
+
+func (h *Handler) handleThreadModerate() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		t := model.ThreadModerate{}
+		body, err := ioutil.ReadAll(c.Request().Body)
+		if err := json.Unmarshal(body, &t); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"message": err.Error(),
+			})
+		}
+		thread, err := h.usecase.moderateThread(c.Param("slug_or_id"), t)
+		if err != nil {
+			return Error(c, err)
+		}
+		return c.JSON(http.StatusOK, thread)
+	}
+}
+
+func (h *Handler) handleThreadDelete() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		purge, _ := strconv.ParseBool(c.QueryParam("purge"))
+		if purge {
+			if err := h.usecase.purgeThread(c.Param("slug_or_id")); err != nil {
+				return Error(c, err)
+			}
+			return c.NoContent(http.StatusNoContent)
+		}
+		thread, err := h.usecase.deleteThread(c.Param("slug_or_id"))
+		if err != nil {
+			return Error(c, err)
+		}
+		return c.JSON(http.StatusOK, thread)
+	}
+}
+
+func (h *Handler) handleThreadRestore() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		thread, err := h.usecase.restoreThread(c.Param("slug_or_id"))
+		if err != nil {
+			return Error(c, err)
+		}
+		return c.JSON(http.StatusOK, thread)
+	}
+}
+
 func (h *Handler) handleGetThreadPosts() echo.HandlerFunc {
 	return func(c echo.Context) error {
 		sp := c.QueryParam("since")
@@ -258,20 +554,49 @@ func (h *Handler) handleGetThreadPosts() echo.HandlerFunc {
 		}
 		limit, _ := strconv.Atoi(c.QueryParam("limit"))
 		desc, _ := strconv.ParseBool(c.QueryParam("desc"))
-		posts, err := h.usecase.getThreadPosts(
+		if c.QueryParam("format") == formatNested {
+			tree, page, err := h.usecase.getThreadPostsNested(
+				c.Param("slug_or_id"),
+				limit,
+				since,
+				c.QueryParam("cursor"),
+				c.QueryParam("sort"),
+				desc,
+			)
+			if err != nil {
+				return Error(c, err)
+			}
+			setPageLinks(c, page)
+			return c.JSON(http.StatusOK, tree)
+		}
+		posts, page, err := h.usecase.getThreadPosts(
 			c.Param("slug_or_id"),
 			limit,
 			since,
+			c.QueryParam("cursor"),
 			c.QueryParam("sort"),
 			desc,
 		)
 		if err != nil {
 			return Error(c, err)
 		}
+		setPageLinks(c, page)
 		return c.JSON(http.StatusOK, posts)
 	}
 }
 
+func (h *Handler) handleGetThreadParticipants() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		limit, _ := strconv.Atoi(c.QueryParam("limit"))
+		participants, page, err := h.usecase.getThreadParticipants(c.Param("slug_or_id"), c.QueryParam("cursor"), limit)
+		if err != nil {
+			return Error(c, err)
+		}
+		setPageLinks(c, page)
+		return c.JSON(http.StatusOK, participants)
+	}
+}
+
 func (h *Handler) handleGetPostDetails() echo.HandlerFunc {
 	return func(c echo.Context) error {
 		id, _ := strconv.Atoi(c.Param("id"))
@@ -307,7 +632,7 @@ func (h *Handler) handlePostUpdate() echo.HandlerFunc {
 			})
 		}
 		id, _ := strconv.Atoi(c.Param("id"))
-		thread, err := h.usecase.updatePost(id, t.Message)
+		thread, err := h.usecase.updatePost(id, t.Message, t.Editor)
 		if err != nil {
 			return Error(c, err)
 		}
@@ -315,6 +640,162 @@ func (h *Handler) handlePostUpdate() echo.HandlerFunc {
 	}
 }
 
+func (h *Handler) handlePostDelete() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id, _ := strconv.Atoi(c.Param("id"))
+		purge, _ := strconv.ParseBool(c.QueryParam("purge"))
+		if purge {
+			if err := h.usecase.purgePost(id); err != nil {
+				return Error(c, err)
+			}
+			return c.NoContent(http.StatusNoContent)
+		}
+		post, err := h.usecase.deletePost(id)
+		if err != nil {
+			return Error(c, err)
+		}
+		return c.JSON(http.StatusOK, post)
+	}
+}
+
+func (h *Handler) handlePostSplit() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		thread := model.ThreadCreate{}
+		body, err := ioutil.ReadAll(c.Request().Body)
+		if err := json.Unmarshal(body, &thread); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"message": err.Error(),
+			})
+		}
+		id, _ := strconv.Atoi(c.Param("id"))
+		result, err := h.usecase.splitThread(id, thread)
+		if errors.Is(err, consts.ErrConflict) && result != nil {
+			return c.JSON(http.StatusConflict, result)
+		}
+		if err != nil {
+			return Error(c, err)
+		}
+		return c.JSON(http.StatusCreated, result)
+	}
+}
+
+func (h *Handler) handleGetPostReplies() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id, _ := strconv.Atoi(c.Param("id"))
+		sp := c.QueryParam("since")
+		var since *int = nil
+		if sp != "" {
+			n, _ := strconv.Atoi(sp)
+			since = &n
+		}
+		limit, _ := strconv.Atoi(c.QueryParam("limit"))
+		depth, _ := strconv.Atoi(c.QueryParam("depth"))
+		desc, _ := strconv.ParseBool(c.QueryParam("desc"))
+		posts, page, err := h.usecase.getPostReplies(id, limit, since, c.QueryParam("cursor"), depth, desc)
+		if err != nil {
+			return Error(c, err)
+		}
+		setPageLinks(c, page)
+		return c.JSON(http.StatusOK, posts)
+	}
+}
+
+func (h *Handler) handleGetPostAncestors() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id, _ := strconv.Atoi(c.Param("id"))
+		posts, err := h.usecase.getPostAncestors(id)
+		if err != nil {
+			return Error(c, err)
+		}
+		return c.JSON(http.StatusOK, posts)
+	}
+}
+
+func (h *Handler) handleGetPostContext() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id, _ := strconv.Atoi(c.Param("id"))
+		count, _ := strconv.Atoi(c.QueryParam("count"))
+		if count <= 0 {
+			count = defaultContextCount
+		}
+		limit, _ := strconv.Atoi(c.QueryParam("limit"))
+		context, err := h.usecase.getPostContext(id, count, limit, c.QueryParam("sort"))
+		if err != nil {
+			return Error(c, err)
+		}
+		return c.JSON(http.StatusOK, context)
+	}
+}
+
+func (h *Handler) handleGetPostRevisions() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id, _ := strconv.Atoi(c.Param("id"))
+		revisions, err := h.usecase.getPostRevisions(id)
+		if err != nil {
+			return Error(c, err)
+		}
+		return c.JSON(http.StatusOK, revisions)
+	}
+}
+
+func (h *Handler) handleGetPostRevision() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id, _ := strconv.Atoi(c.Param("id"))
+		revision, _ := strconv.Atoi(c.Param("revision"))
+		result, err := h.usecase.getPostRevision(id, revision)
+		if err != nil {
+			return Error(c, err)
+		}
+		return c.JSON(http.StatusOK, result)
+	}
+}
+
+func (h *Handler) handleGetPostDiff() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id, _ := strconv.Atoi(c.Param("id"))
+		var from, to *int = nil, nil
+		if fp := c.QueryParam("from"); fp != "" {
+			n, _ := strconv.Atoi(fp)
+			from = &n
+		}
+		if tp := c.QueryParam("to"); tp != "" {
+			n, _ := strconv.Atoi(tp)
+			to = &n
+		}
+		result, err := h.usecase.getPostDiff(id, from, to)
+		if err != nil {
+			return Error(c, err)
+		}
+		return c.JSON(http.StatusOK, result)
+	}
+}
+
+func (h *Handler) handleSearch() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		thread, _ := strconv.Atoi(c.QueryParam("thread"))
+		limit, _ := strconv.Atoi(c.QueryParam("limit"))
+		filter := model.SearchFilter{
+			Query:  c.QueryParam("q"),
+			Type:   c.QueryParam("type"),
+			Forum:  c.QueryParam("forum"),
+			Thread: thread,
+			Author: c.QueryParam("author"),
+			From:   c.QueryParam("from"),
+			To:     c.QueryParam("to"),
+		}
+		cursor := c.QueryParam("cursor")
+		if cursor == "" {
+			cursor = c.QueryParam("since")
+		}
+		result, err := h.usecase.search(filter, cursor, limit)
+		if err != nil {
+			return Error(c, err)
+		}
+		setPageLinks(c, &result.Page)
+		return c.JSON(http.StatusOK, result)
+	}
+}
+
 func (h *Handler) handleStatus() echo.HandlerFunc {
 	return func(c echo.Context) error {
 		status, err := h.usecase.getStatus()
@@ -335,7 +816,37 @@ func (h *Handler) handleClear() echo.HandlerFunc {
 	}
 }
 
+// setPageLinks points the Link header at the neighbouring pages of a list.
+// The links repeat the request with the cursor in place of since.
+func setPageLinks(c echo.Context, page *model.Page) {
+	links := make([]string, 0, 2)
+	for _, link := range []struct{ rel, cursor string }{{"next", page.Next}, {"prev", page.Prev}} {
+		if link.cursor == "" {
+			continue
+		}
+		target := *c.Request().URL
+		query := target.Query()
+		query.Del("since")
+		query.Set("cursor", link.cursor)
+		target.RawQuery = query.Encode()
+		links = append(links, fmt.Sprintf(`<%s>; rel="%s"`, target.RequestURI(), link.rel))
+	}
+	if len(links) > 0 {
+		c.Response().Header().Set("Link", strings.Join(links, ", "))
+	}
+}
+
 func Error(c echo.Context, err error) error {
+	if errors.Is(err, consts.ErrBadRequest) {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"message": err.Error(),
+		})
+	}
+	if errors.Is(err, consts.ErrForbidden) {
+		return c.JSON(http.StatusForbidden, map[string]string{
+			"message": err.Error(),
+		})
+	}
 	if errors.Is(err, consts.ErrNotFound) {
 		return c.JSON(http.StatusNotFound, map[string]string{
 			"message": err.Error(),
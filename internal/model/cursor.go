@@ -0,0 +1,137 @@
+package model
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"project/internal/consts"
+	"strconv"
+	"time"
+)
+
+type (
+	// Cursor is a keyset position in a list: the sort the list is ordered by, the sort key
+	// values and the id of the row a page starts next to. Keeping the key in the cursor
+	// means a page does not move when that row's score changes. Before pages run backwards.
+	// Pinned marks positions among the pinned threads listed ahead of a forum's other threads.
+	Cursor struct {
+		Sort   string   `json:"s,omitempty"`
+		Key    []string `json:"k,omitempty"`
+		ID     string   `json:"i"`
+		Before bool     `json:"b,omitempty"`
+		Pinned bool     `json:"p,omitempty"`
+	}
+
+	Page struct {
+		Next string `json:"next,omitempty"`
+		Prev string `json:"prev,omitempty"`
+	}
+)
+
+func ParseCursor(raw string) (Cursor, error) {
+	var cursor Cursor
+	decoded, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil || json.Unmarshal(decoded, &cursor) != nil || cursor.ID == "" {
+		return Cursor{}, fmt.Errorf("%w: malformed cursor", consts.ErrBadRequest)
+	}
+	return cursor, nil
+}
+
+func (c Cursor) String() string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func (c Cursor) IntID() (*int, error) {
+	if c.ID == "" {
+		return nil, nil
+	}
+	id, err := strconv.Atoi(c.ID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed cursor", consts.ErrBadRequest)
+	}
+	return &id, nil
+}
+
+// KeyString returns the sort key value at i; the typed getters below parse it.
+func (c Cursor) KeyString(i int) (string, error) {
+	if i >= len(c.Key) {
+		return "", fmt.Errorf("%w: malformed cursor", consts.ErrBadRequest)
+	}
+	return c.Key[i], nil
+}
+
+func (c Cursor) KeyInt(i int) (int, error) {
+	key, err := c.KeyString(i)
+	if err != nil {
+		return 0, err
+	}
+	value, err := strconv.Atoi(key)
+	if err != nil {
+		return 0, fmt.Errorf("%w: malformed cursor", consts.ErrBadRequest)
+	}
+	return value, nil
+}
+
+func (c Cursor) KeyFloat(i int) (float64, error) {
+	key, err := c.KeyString(i)
+	if err != nil {
+		return 0, err
+	}
+	value, err := strconv.ParseFloat(key, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: malformed cursor", consts.ErrBadRequest)
+	}
+	return value, nil
+}
+
+// KeyTime returns nil for an empty value, which stands for a null column.
+func (c Cursor) KeyTime(i int) (*time.Time, error) {
+	key, err := c.KeyString(i)
+	if err != nil || key == "" {
+		return nil, err
+	}
+	value, err := time.Parse(time.RFC3339Nano, key)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed cursor", consts.ErrBadRequest)
+	}
+	return &value, nil
+}
+
+// ThreadCursor records what every thread ordering is computed from: votes, creation and last post time.
+func ThreadCursor(thread *Thread) Cursor {
+	lastPostAt := ""
+	if thread.LastPostAt != nil {
+		lastPostAt = *thread.LastPostAt
+	}
+	return Cursor{
+		Key:    []string{strconv.Itoa(thread.Votes), thread.Created, lastPostAt},
+		ID:     strconv.Itoa(thread.ID),
+		Pinned: thread.IsPinned,
+	}
+}
+
+func PostCursor(post *Post) Cursor {
+	return Cursor{Key: []string{post.Created}, ID: strconv.Itoa(post.ID)}
+}
+
+// NewPage builds the cursors around a page from the positions of its rows in list order.
+// at is where the page was requested from; paged tells whether it was the first page.
+func NewPage(at Cursor, paged bool, rows []Cursor, limit int) *Page {
+	page := &Page{}
+	if len(rows) == 0 {
+		return page
+	}
+	full := limit > 0 && len(rows) == limit
+	if full || at.Before {
+		next := rows[len(rows)-1]
+		next.Sort, next.Before = at.Sort, false
+		page.Next = next.String()
+	}
+	if (paged && !at.Before) || (at.Before && full) {
+		prev := rows[0]
+		prev.Sort, prev.Before = at.Sort, true
+		page.Prev = prev.String()
+	}
+	return page
+}
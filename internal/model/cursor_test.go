@@ -0,0 +1,141 @@
+package model
+
+import (
+	"encoding/base64"
+	"errors"
+	"project/internal/consts"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	tests := []Cursor{
+		{ID: "1"},
+		{Sort: "hot", Key: []string{"3", "2020-01-01T00:00:00.5Z", ""}, ID: "42"},
+		{Sort: "title", Key: []string{"a, b|c"}, ID: "slug", Before: true},
+		{Sort: "top", Key: []string{"1", "2020-01-01T00:00:00Z", ""}, ID: "3", Pinned: true},
+	}
+	for _, cursor := range tests {
+		parsed, err := ParseCursor(cursor.String())
+		if err != nil {
+			t.Fatalf("ParseCursor(%+v) error = %v", cursor, err)
+		}
+		if !reflect.DeepEqual(parsed, cursor) {
+			t.Errorf("ParseCursor(String()) = %+v, want %+v", parsed, cursor)
+		}
+	}
+}
+
+func TestParseCursorMalformed(t *testing.T) {
+	tests := map[string]string{
+		"not base64": "!!!",
+		"not json":   base64.RawURLEncoding.EncodeToString([]byte("a,hot,1")),
+		"no id":      base64.RawURLEncoding.EncodeToString([]byte(`{"s":"hot"}`)),
+		"empty":      "",
+	}
+	for name, raw := range tests {
+		if _, err := ParseCursor(raw); !errors.Is(err, consts.ErrBadRequest) {
+			t.Errorf("%s: ParseCursor(%q) error = %v, want %v", name, raw, err, consts.ErrBadRequest)
+		}
+	}
+}
+
+func TestCursorKeys(t *testing.T) {
+	cursor := Cursor{Key: []string{"7", "0.25", "2020-01-02T03:04:05.123456Z", "", "x"}, ID: "12"}
+
+	if id, err := cursor.IntID(); err != nil || *id != 12 {
+		t.Errorf("IntID() = %v, %v, want 12", id, err)
+	}
+	if value, err := cursor.KeyInt(0); err != nil || value != 7 {
+		t.Errorf("KeyInt(0) = %d, %v, want 7", value, err)
+	}
+	if value, err := cursor.KeyFloat(1); err != nil || value != 0.25 {
+		t.Errorf("KeyFloat(1) = %v, %v, want 0.25", value, err)
+	}
+	want := time.Date(2020, 1, 2, 3, 4, 5, 123456000, time.UTC)
+	if value, err := cursor.KeyTime(2); err != nil || value == nil || !value.Equal(want) {
+		t.Errorf("KeyTime(2) = %v, %v, want %v", value, err, want)
+	}
+	if value, err := cursor.KeyTime(3); err != nil || value != nil {
+		t.Errorf("KeyTime(3) = %v, %v, want nil for an empty value", value, err)
+	}
+
+	malformed := map[string]func() error{
+		"int":     func() error { _, err := cursor.KeyInt(4); return err },
+		"float":   func() error { _, err := cursor.KeyFloat(4); return err },
+		"time":    func() error { _, err := cursor.KeyTime(4); return err },
+		"missing": func() error { _, err := cursor.KeyString(5); return err },
+		"id":      func() error { _, err := Cursor{ID: "slug"}.IntID(); return err },
+	}
+	for name, read := range malformed {
+		if err := read(); !errors.Is(err, consts.ErrBadRequest) {
+			t.Errorf("%s: error = %v, want %v", name, err, consts.ErrBadRequest)
+		}
+	}
+}
+
+func TestThreadCursor(t *testing.T) {
+	lastPostAt := "2020-01-03T00:00:00Z"
+	thread := &Thread{ID: 5, Votes: -2, Created: "2020-01-01T00:00:00Z", LastPostAt: &lastPostAt}
+	want := Cursor{Key: []string{"-2", "2020-01-01T00:00:00Z", lastPostAt}, ID: "5"}
+	if got := ThreadCursor(thread); !reflect.DeepEqual(got, want) {
+		t.Errorf("ThreadCursor() = %+v, want %+v", got, want)
+	}
+	thread.LastPostAt = nil
+	if got := ThreadCursor(thread); got.Key[2] != "" {
+		t.Errorf("ThreadCursor() without posts has last post key %q, want empty", got.Key[2])
+	}
+	thread.IsPinned = true
+	if got := ThreadCursor(thread); !got.Pinned {
+		t.Errorf("ThreadCursor() of a pinned thread = %+v, want a pinned position", got)
+	}
+}
+
+func TestNewPage(t *testing.T) {
+	rows := []Cursor{{Key: []string{"3"}, ID: "a"}, {Key: []string{"2"}, ID: "b"}}
+	last := Cursor{Sort: "top", Key: []string{"2"}, ID: "b"}
+	prev := Cursor{Sort: "top", Key: []string{"3"}, ID: "a", Before: true}
+
+	tests := []struct {
+		name       string
+		at         Cursor
+		paged      bool
+		rows       []Cursor
+		limit      int
+		next, prev *Cursor
+	}{
+		{name: "empty", at: Cursor{Sort: "top"}, rows: nil, limit: 2},
+		{name: "full first page", at: Cursor{Sort: "top"}, rows: rows, limit: 2, next: &last},
+		{name: "last first page", at: Cursor{Sort: "top"}, rows: rows, limit: 3},
+		{name: "no limit", at: Cursor{Sort: "top"}, rows: rows, limit: 0},
+		{name: "full later page", at: Cursor{Sort: "top", ID: "x"}, paged: true, rows: rows, limit: 2, next: &last, prev: &prev},
+		{name: "last later page", at: Cursor{Sort: "top", ID: "x"}, paged: true, rows: rows, limit: 3, prev: &prev},
+		{name: "full before page", at: Cursor{Sort: "top", ID: "x", Before: true}, paged: true, rows: rows, limit: 2, next: &last, prev: &prev},
+		{name: "first before page", at: Cursor{Sort: "top", ID: "x", Before: true}, paged: true, rows: rows, limit: 3, next: &last},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page := NewPage(tt.at, tt.paged, tt.rows, tt.limit)
+			checkPageCursor(t, "next", page.Next, tt.next)
+			checkPageCursor(t, "prev", page.Prev, tt.prev)
+		})
+	}
+}
+
+func checkPageCursor(t *testing.T, name, raw string, want *Cursor) {
+	t.Helper()
+	if want == nil {
+		if raw != "" {
+			t.Errorf("%s = %q, want none", name, raw)
+		}
+		return
+	}
+	got, err := ParseCursor(raw)
+	if err != nil {
+		t.Fatalf("%s = %q: %v", name, raw, err)
+	}
+	if !reflect.DeepEqual(got, *want) {
+		t.Errorf("%s = %+v, want %+v", name, got, *want)
+	}
+}
@@ -10,37 +10,60 @@ type (
 	}
 
 	Forum struct {
-		ID      int    `db:"id" json:"-"`
-		Title   string `db:"title" json:"title"`
-		User    string `db:"user" json:"user"`
-		Slug    string `db:"slug" json:"slug"`
-		Posts   int    `db:"posts" json:"posts"`
-		Threads int    `db:"threads" json:"threads"`
+		ID         int    `db:"id" json:"-"`
+		Title      string `db:"title" json:"title"`
+		User       string `db:"user" json:"user"`
+		Slug       string `db:"slug" json:"slug"`
+		Posts      int    `db:"posts" json:"posts"`
+		Threads    int    `db:"threads" json:"threads"`
+		Created    string `db:"created" json:"-"`
+		IsArchived bool   `db:"isArchived" json:"isArchived,omitempty"`
 	}
 
 	Thread struct {
-		ID      int    `db:"id" json:"id"`
-		Title   string `db:"title" json:"title"`
-		Author  string `db:"author" json:"author"`
-		Forum   string `db:"forum" json:"forum"`
-		Message string `db:"message" json:"message"`
-		Votes   int    `db:"votes" json:"votes"`
-		Slug    string `db:"slug" json:"slug"`
-		Created string `db:"created" json:"created"`
+		ID             int     `db:"id" json:"id"`
+		Title          string  `db:"title" json:"title"`
+		Author         string  `db:"author" json:"author"`
+		Forum          string  `db:"forum" json:"forum"`
+		Message        string  `db:"message" json:"message"`
+		Votes          int     `db:"votes" json:"votes"`
+		Slug           string  `db:"slug" json:"slug"`
+		Created        string  `db:"created" json:"created"`
+		IsDeleted      bool    `db:"isDeleted" json:"isDeleted,omitempty"`
+		IsLocked       bool    `db:"isLocked" json:"isLocked,omitempty"`
+		IsPinned       bool    `db:"isPinned" json:"isPinned,omitempty"`
+		IsArchived     bool    `db:"isArchived" json:"isArchived,omitempty"`
+		Posts          int     `db:"posts" json:"posts"`
+		LastPostAt     *string `db:"last_post_at" json:"lastPostAt,omitempty"`
+		LastPostAuthor *string `db:"last_post_author" json:"lastPostAuthor,omitempty"`
 	}
 
 	Post struct {
-		ID       int    `db:"id" json:"id"`
-		Parent   int    `db:"parent" json:"parent"`
-		Path     string `db:"path" json:"-"`
-		Author   string `db:"author" json:"author"`
-		Forum    string `db:"forum" json:"forum"`
-		Thread   int    `db:"thread" json:"thread"`
+		ID        int      `db:"id" json:"id"`
+		Parent    int      `db:"parent" json:"parent"`
+		Path      PostPath `db:"path" json:"-"`
+		Author    string   `db:"author" json:"author"`
+		Forum     string   `db:"forum" json:"forum"`
+		Thread    int      `db:"thread" json:"thread"`
+		Message   string   `db:"message" json:"message"`
+		IsEdited  bool     `db:"isEdited" json:"isEdited"`
+		IsDeleted bool     `db:"isDeleted" json:"isDeleted,omitempty"`
+		Created   string   `db:"created" json:"created"`
+	}
+
+	PostRevision struct {
+		Post     int    `db:"post" json:"post"`
+		Revision int    `db:"revision" json:"revision"`
 		Message  string `db:"message" json:"message"`
-		IsEdited bool   `db:"isEdited" json:"isEdited"`
+		Editor   string `db:"editor" json:"editor,omitempty"`
 		Created  string `db:"created" json:"created"`
 	}
 
+	Participant struct {
+		Nickname string `db:"author" json:"nickname"`
+		Posts    int    `db:"posts" json:"posts"`
+	}
+
 	VoteDB struct {
 		ID       int    `db:"id" json:"id"`
 		Thread   int    `db:"thread" json:"thread"`
@@ -49,6 +72,7 @@ type (
 	}
 
 	Users   = []*User
+	Forums  = []*Forum
 	Threads = []*Thread
 	Posts   = []*Post
 )
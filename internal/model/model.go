@@ -1,5 +1,7 @@
 package model
 
+import "project/internal/diff"
+
 type (
 	UserInput struct {
 		Email    string `json:"email"`
@@ -7,12 +9,22 @@ type (
 		About    string `json:"about"`
 	}
 
+	UserRename struct {
+		Nickname string `json:"nickname"`
+	}
+
 	ForumCreate struct {
 		Slug  string `json:"slug"`
 		Title string `json:"title"`
 		User  string `json:"user"`
 	}
 
+	ForumUpdate struct {
+		Title      string `json:"title"`
+		User       string `json:"user"`
+		IsArchived *bool  `json:"isArchived"`
+	}
+
 	ThreadCreate struct {
 		Author  string `json:"author"`
 		Created string `json:"created"`
@@ -26,6 +38,20 @@ type (
 		Title   string `json:"title"`
 	}
 
+	ThreadMove struct {
+		Forum string `json:"forum"`
+	}
+
+	ThreadMerge struct {
+		Target string `json:"target"`
+	}
+
+	ThreadModerate struct {
+		IsLocked   *bool `json:"isLocked"`
+		IsPinned   *bool `json:"isPinned"`
+		IsArchived *bool `json:"isArchived"`
+	}
+
 	PostCreate struct {
 		Author  string `json:"author"`
 		Message string `json:"message"`
@@ -34,6 +60,7 @@ type (
 
 	PostUpdate struct {
 		Message string `json:"message"`
+		Editor  string `json:"editor"`
 	}
 
 	Vote struct {
@@ -41,6 +68,59 @@ type (
 		Voice    int    `json:"voice"`
 	}
 
+	PostContext struct {
+		Post     *Post `json:"post"`
+		Before   Posts `json:"before"`
+		After    Posts `json:"after"`
+		Position int   `json:"position"`
+		Page     int   `json:"page"`
+	}
+
+	PostDiff struct {
+		Post    int           `json:"post"`
+		From    int           `json:"from"`
+		To      int           `json:"to"`
+		Changes []diff.Change `json:"changes"`
+	}
+
+	ExportPost struct {
+		Post
+		ThreadTitle string `db:"threadTitle" json:"threadTitle"`
+		ThreadSlug  string `db:"threadSlug" json:"threadSlug"`
+	}
+
+	ExportRecord struct {
+		Type string      `json:"type"`
+		Data interface{} `json:"data"`
+	}
+
+	SearchFilter struct {
+		Query  string
+		Type   string
+		Forum  string
+		Thread int
+		Author string
+		From   string
+		To     string
+	}
+
+	SearchHit struct {
+		Kind    string  `db:"kind" json:"type"`
+		ID      int     `db:"id" json:"id"`
+		Thread  int     `db:"thread" json:"thread"`
+		Forum   string  `db:"forum" json:"forum"`
+		Author  string  `db:"author" json:"author"`
+		Created string  `db:"created" json:"created"`
+		Title   string  `db:"title" json:"title,omitempty"`
+		Snippet string  `db:"snippet" json:"snippet"`
+		Rank    float32 `db:"rank" json:"rank"`
+	}
+
+	SearchResult struct {
+		Hits []*SearchHit `json:"hits"`
+		Page
+	}
+
 	Status struct {
 		Forum  int `json:"forum"`
 		Post   int `json:"post"`
@@ -0,0 +1,97 @@
+package model
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PostPath is the materialized path of a post: ids from the thread root down to the post itself.
+// It is stored as bigint[], so postgres array ordering gives the tree order.
+type PostPath []int
+
+func (p PostPath) Root() int {
+	if len(p) == 0 {
+		return 0
+	}
+	return p[0]
+}
+
+// Ancestors is the path without the post itself. Empty paths, left by posts created
+// before paths were stored, have no ancestors.
+func (p PostPath) Ancestors() PostPath {
+	if len(p) == 0 {
+		return PostPath{}
+	}
+	return p[:len(p)-1]
+}
+
+// SplitFrom is the path p, of root or a post under it, takes when root is split off into a
+// thread of its own: the ids above root are dropped. splitThread rewrites paths the same way in
+// sql as path[len(root):], postgres arrays being indexed from 1.
+func (p PostPath) SplitFrom(root PostPath) PostPath {
+	if len(root) == 0 || len(p) < len(root) {
+		return p
+	}
+	return p[len(root)-1:]
+}
+
+func (p PostPath) Child(id int) PostPath {
+	child := make(PostPath, len(p), len(p)+1)
+	copy(child, p)
+	return append(child, id)
+}
+
+func (p PostPath) String() string {
+	ids := make([]string, 0, len(p))
+	for _, id := range p {
+		ids = append(ids, strconv.Itoa(id))
+	}
+	return "{" + strings.Join(ids, ",") + "}"
+}
+
+func (p PostPath) Value() (driver.Value, error) {
+	return p.String(), nil
+}
+
+func (p *PostPath) Scan(src interface{}) error {
+	var raw string
+	switch v := src.(type) {
+	case string:
+		raw = v
+	case []byte:
+		raw = string(v)
+	case nil:
+		*p = nil
+		return nil
+	default:
+		return fmt.Errorf("cannot scan %T into PostPath", src)
+	}
+	raw = strings.Trim(raw, "{}")
+	if raw == "" {
+		*p = PostPath{}
+		return nil
+	}
+	parts := strings.Split(raw, ",")
+	path := make(PostPath, 0, len(parts))
+	for _, part := range parts {
+		id, err := strconv.Atoi(part)
+		if err != nil {
+			return err
+		}
+		path = append(path, id)
+	}
+	*p = path
+	return nil
+}
+
+// UpperBound is the first path after every descendant of p, so descendants are in (p, p.UpperBound()).
+func (p PostPath) UpperBound() PostPath {
+	bound := make(PostPath, len(p))
+	copy(bound, p)
+	if len(bound) > 0 {
+		bound[len(bound)-1]++
+	}
+	return bound
+}
@@ -0,0 +1,130 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPostPathScan(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     interface{}
+		want    PostPath
+		wantErr bool
+	}{
+		{name: "string", src: "{1,2,3}", want: PostPath{1, 2, 3}},
+		{name: "bytes", src: []byte("{42}"), want: PostPath{42}},
+		{name: "empty", src: "{}", want: PostPath{}},
+		{name: "nil", src: nil, want: nil},
+		{name: "bad element", src: "{1,x}", wantErr: true},
+		{name: "bad type", src: 12, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var path PostPath
+			err := path.Scan(tt.src)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Scan(%v) error = %v, wantErr %v", tt.src, err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(path, tt.want) {
+				t.Errorf("Scan(%v) = %v, want %v", tt.src, path, tt.want)
+			}
+		})
+	}
+}
+
+func TestPostPathValue(t *testing.T) {
+	tests := []struct {
+		path PostPath
+		want string
+	}{
+		{path: PostPath{1, 2, 3}, want: "{1,2,3}"},
+		{path: PostPath{7}, want: "{7}"},
+		{path: PostPath{}, want: "{}"},
+	}
+	for _, tt := range tests {
+		value, err := tt.path.Value()
+		if err != nil {
+			t.Fatalf("Value() error = %v", err)
+		}
+		if value != tt.want {
+			t.Errorf("Value() = %v, want %v", value, tt.want)
+		}
+		var scanned PostPath
+		if err := scanned.Scan(value); err != nil || !reflect.DeepEqual(scanned, tt.path) {
+			t.Errorf("Scan(Value()) = %v, %v, want %v", scanned, err, tt.path)
+		}
+	}
+}
+
+func TestPostPathUpperBound(t *testing.T) {
+	tests := []struct {
+		path PostPath
+		want PostPath
+	}{
+		{path: PostPath{1, 2, 3}, want: PostPath{1, 2, 4}},
+		{path: PostPath{5}, want: PostPath{6}},
+		{path: PostPath{}, want: PostPath{}},
+	}
+	for _, tt := range tests {
+		original := append(PostPath{}, tt.path...)
+		if got := tt.path.UpperBound(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%v.UpperBound() = %v, want %v", tt.path, got, tt.want)
+		}
+		if !reflect.DeepEqual(tt.path, original) {
+			t.Errorf("UpperBound modified its receiver: %v, want %v", tt.path, original)
+		}
+	}
+}
+
+func TestPostPathChild(t *testing.T) {
+	parent := PostPath{1, 2}
+	first := parent.Child(3)
+	second := parent.Child(4)
+	if !reflect.DeepEqual(first, PostPath{1, 2, 3}) || !reflect.DeepEqual(second, PostPath{1, 2, 4}) {
+		t.Errorf("Child() = %v, %v, want {1,2,3}, {1,2,4}", first, second)
+	}
+	if root := first.Root(); root != 1 {
+		t.Errorf("Root() = %d, want 1", root)
+	}
+	if root := (PostPath{}).Root(); root != 0 {
+		t.Errorf("empty Root() = %d, want 0", root)
+	}
+}
+
+func TestPostPathAncestors(t *testing.T) {
+	tests := []struct {
+		path PostPath
+		want PostPath
+	}{
+		{path: PostPath{1, 2, 3}, want: PostPath{1, 2}},
+		{path: PostPath{1}, want: PostPath{}},
+		{path: PostPath{}, want: PostPath{}},
+		{path: nil, want: PostPath{}},
+	}
+	for _, tt := range tests {
+		if got := tt.path.Ancestors(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%v.Ancestors() = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestPostPathSplitFrom(t *testing.T) {
+	tests := []struct {
+		name       string
+		path, root PostPath
+		want       PostPath
+	}{
+		{name: "root", path: PostPath{1, 2}, root: PostPath{1, 2}, want: PostPath{2}},
+		{name: "descendant", path: PostPath{1, 2, 3, 4}, root: PostPath{1, 2}, want: PostPath{2, 3, 4}},
+		{name: "thread root", path: PostPath{1, 5}, root: PostPath{1}, want: PostPath{1, 5}},
+		{name: "empty root", path: PostPath{1, 5}, root: PostPath{}, want: PostPath{1, 5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.path.SplitFrom(tt.root); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SplitFrom(%v) = %v, want %v", tt.root, got, tt.want)
+			}
+		})
+	}
+}
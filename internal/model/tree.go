@@ -0,0 +1,34 @@
+package model
+
+type (
+	PostNode struct {
+		*Post
+		Children  []*PostNode `json:"children"`
+		Truncated bool        `json:"truncated,omitempty"`
+	}
+
+	PostNodes = []*PostNode
+)
+
+// NestPosts builds reply trees from posts in any order, keeping that order among siblings.
+// Posts whose parent is not in the list become roots. replies holds the number
+// of direct replies stored for each post and marks nodes whose children were cut off.
+func NestPosts(posts Posts, replies map[int]int) PostNodes {
+	nodes := make(map[int]*PostNode, len(posts))
+	for _, post := range posts {
+		nodes[post.ID] = &PostNode{Post: post, Children: make(PostNodes, 0)}
+	}
+	roots := make(PostNodes, 0)
+	for _, post := range posts {
+		node := nodes[post.ID]
+		if parent, ok := nodes[post.Parent]; ok && post.Parent != 0 {
+			parent.Children = append(parent.Children, node)
+		} else {
+			roots = append(roots, node)
+		}
+	}
+	for _, node := range nodes {
+		node.Truncated = replies[node.ID] > len(node.Children)
+	}
+	return roots
+}
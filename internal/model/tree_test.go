@@ -0,0 +1,69 @@
+package model
+
+import "testing"
+
+func TestNestPosts(t *testing.T) {
+	posts := Posts{
+		{ID: 1, Parent: 0},
+		{ID: 2, Parent: 1},
+		{ID: 3, Parent: 2},
+		{ID: 4, Parent: 1},
+		{ID: 5, Parent: 0},
+		{ID: 6, Parent: 99},
+	}
+	replies := map[int]int{1: 2, 2: 1, 4: 3}
+	roots := NestPosts(posts, replies)
+
+	if ids := nodeIDs(roots); !equalIDs(ids, []int{1, 5, 6}) {
+		t.Fatalf("roots = %v, want [1 5 6]", ids)
+	}
+	first := roots[0]
+	if ids := nodeIDs(first.Children); !equalIDs(ids, []int{2, 4}) {
+		t.Errorf("children of 1 = %v, want [2 4]", ids)
+	}
+	if ids := nodeIDs(first.Children[0].Children); !equalIDs(ids, []int{3}) {
+		t.Errorf("children of 2 = %v, want [3]", ids)
+	}
+
+	truncated := map[int]bool{1: false, 2: false, 3: false, 4: true, 5: false, 6: false}
+	var walk func(nodes PostNodes)
+	walk = func(nodes PostNodes) {
+		for _, node := range nodes {
+			if node.Truncated != truncated[node.ID] {
+				t.Errorf("post %d truncated = %v, want %v", node.ID, node.Truncated, truncated[node.ID])
+			}
+			if node.Children == nil {
+				t.Errorf("post %d has nil children, want empty list", node.ID)
+			}
+			walk(node.Children)
+		}
+	}
+	walk(roots)
+}
+
+func TestNestPostsEmpty(t *testing.T) {
+	roots := NestPosts(Posts{}, nil)
+	if roots == nil || len(roots) != 0 {
+		t.Errorf("NestPosts(empty) = %v, want empty list", roots)
+	}
+}
+
+func nodeIDs(nodes PostNodes) []int {
+	ids := make([]int, 0, len(nodes))
+	for _, node := range nodes {
+		ids = append(ids, node.ID)
+	}
+	return ids
+}
+
+func equalIDs(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
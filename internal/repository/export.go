@@ -0,0 +1,128 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"github.com/jmoiron/sqlx"
+	"project/internal/model"
+)
+
+// ExportUser streams a user's profile, threads, posts, votes and forums to fn. Everything is
+// read in one read-only repeatable read transaction, so the export is a single snapshot even
+// though the user may keep posting while it is written out.
+func (r *Repository) ExportUser(nickname string, fn func(model.ExportRecord) error) error {
+	tx, err := r.db.BeginTxx(context.Background(), &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	if err := r.exportUser(tx, nickname, fn); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *Repository) exportUser(tx *sqlx.Tx, nickname string, fn func(model.ExportRecord) error) error {
+	user, err := r.getUserByNickname(tx, nickname)
+	if err != nil {
+		return err
+	}
+	if err := fn(model.ExportRecord{Type: "profile", Data: user}); err != nil {
+		return err
+	}
+	err = r.eachUserThread(tx, user.Nickname, func(thread *model.Thread) error {
+		return fn(model.ExportRecord{Type: "thread", Data: thread})
+	})
+	if err != nil {
+		return err
+	}
+	err = r.eachUserPost(tx, user.Nickname, func(post *model.ExportPost) error {
+		return fn(model.ExportRecord{Type: "post", Data: post})
+	})
+	if err != nil {
+		return err
+	}
+	err = r.eachUserVote(tx, user.Nickname, func(vote *model.VoteDB) error {
+		return fn(model.ExportRecord{Type: "vote", Data: vote})
+	})
+	if err != nil {
+		return err
+	}
+	forums, err := r.getUserForums(tx, user.Nickname)
+	if err != nil {
+		return err
+	}
+	for _, forum := range forums {
+		if err := fn(model.ExportRecord{Type: "forum", Data: forum}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Repository) eachUserThread(tx *sqlx.Tx, nickname string, fn func(*model.Thread) error) error {
+	return r.eachRow(tx,
+		`select * from thread where author::citext = $1::citext order by id`,
+		[]interface{}{nickname},
+		func(rows *sqlx.Rows) error {
+			thread := model.Thread{}
+			if err := rows.StructScan(&thread); err != nil {
+				return err
+			}
+			return fn(&thread)
+		},
+	)
+}
+
+func (r *Repository) eachUserPost(tx *sqlx.Tx, nickname string, fn func(*model.ExportPost) error) error {
+	return r.eachRow(tx,
+		`select post.*, thread.title as "threadTitle", thread.slug as "threadSlug" from post
+			join thread on thread.id = post.thread
+			where post.author::citext = $1::citext order by post.id`,
+		[]interface{}{nickname},
+		func(rows *sqlx.Rows) error {
+			post := model.ExportPost{}
+			if err := rows.StructScan(&post); err != nil {
+				return err
+			}
+			return fn(&post)
+		},
+	)
+}
+
+func (r *Repository) eachUserVote(tx *sqlx.Tx, nickname string, fn func(*model.VoteDB) error) error {
+	return r.eachRow(tx,
+		`select * from vote where nickname::citext = $1::citext order by id`,
+		[]interface{}{nickname},
+		func(rows *sqlx.Rows) error {
+			vote := model.VoteDB{}
+			if err := rows.StructScan(&vote); err != nil {
+				return err
+			}
+			return fn(&vote)
+		},
+	)
+}
+
+func (r *Repository) getUserForums(tx *sqlx.Tx, nickname string) ([]string, error) {
+	forums := make([]string, 0)
+	err := tx.Select(&forums,
+		`select forum from forum_user where "user"::citext = $1::citext order by forum`,
+		nickname,
+	)
+	return forums, err
+}
+
+func (r *Repository) eachRow(tx *sqlx.Tx, query string, params []interface{}, scan func(rows *sqlx.Rows) error) error {
+	rows, err := tx.Queryx(query, params...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
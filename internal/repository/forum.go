@@ -2,10 +2,36 @@ package repository
 
 import (
 	"fmt"
+	"github.com/jmoiron/sqlx"
+	"project/internal/consts"
 	"project/internal/model"
 	"strings"
 )
 
+const (
+	ForumSortTitle   = "title"
+	ForumSortPosts   = "posts"
+	ForumSortThreads = "threads"
+	ForumSortCreated = "created"
+)
+
+var (
+	forumSortColumns = map[string]string{
+		ForumSortTitle:   "title",
+		ForumSortPosts:   "posts",
+		ForumSortThreads: "threads",
+		ForumSortCreated: "created",
+		"":               "created",
+	}
+	// forumSortTypes casts the sort key a cursor recorded back to its column type.
+	forumSortTypes = map[string]string{
+		"title":   "text",
+		"posts":   "int",
+		"threads": "int",
+		"created": "timestamptz",
+	}
+)
+
 func (r *Repository) GetForumByID(id int) (*model.Forum, error) {
 	return r.getForum("*", "id=$1", id)
 }
@@ -24,16 +50,6 @@ func (r *Repository) getForum(fields, filter string, params ...interface{}) (*mo
 	if err != nil {
 		return nil, Error(err)
 	}
-	var includePosts = fields == "*" || strings.Contains(fields, "posts")
-	if forum.Posts != 0 || !includePosts {
-		return &forum, nil
-	}
-	if forum.Posts, err = r.countForumPosts(forum.Slug); err != nil {
-		return nil, err
-	}
-	if err := r.updateForumPostsCount(forum.ID, forum.Posts); err != nil {
-		return nil, err
-	}
 	return &forum, nil
 }
 
@@ -48,6 +64,96 @@ func (r *Repository) CreateForum(title, slug, user string) (*model.Forum, error)
 	return r.GetForumByID(id)
 }
 
+// UpdateForum changes only the fields given, in one statement, so concurrent
+// updates of different fields, or an archive racing an edit, keep each other's changes.
+func (r *Repository) UpdateForum(slug string, forum model.ForumUpdate) (*model.Forum, error) {
+	updated := model.Forum{}
+	err := r.db.Get(
+		&updated,
+		`update forum set
+			title = coalesce(nullif($1, ''), title),
+			"user" = coalesce(nullif($2, ''), "user"),
+			"isArchived" = coalesce($3, "isArchived")
+		where slug = $4 returning *`,
+		forum.Title, forum.User, forum.IsArchived, slug,
+	)
+	if err != nil {
+		return nil, Error(err)
+	}
+	return &updated, nil
+}
+
+func (r *Repository) DeleteForum(slug string) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return err
+	}
+	if err := r.deleteForum(tx, slug); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *Repository) deleteForum(tx *sqlx.Tx, slug string) error {
+	if err := tx.Get(&slug, `select slug from forum where slug = $1 for update`, slug); err != nil {
+		return Error(err)
+	}
+	queries := []string{
+		`delete from post_revision where post in (select id from post where forum = $1)`,
+		`delete from post where forum = $1`,
+		`delete from vote where thread in (select id from thread where forum = $1)`,
+		`delete from thread where forum = $1`,
+		`delete from forum_user where forum = $1`,
+		`delete from forum where slug = $1`,
+	}
+	for _, query := range queries {
+		if _, err := tx.Exec(query, slug); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetForums pages either after the legacy since slug or after the sort key a cursor recorded.
+func (r *Repository) GetForums(owner, sort, since string, after *model.Cursor, limit int, desc bool) (model.Forums, error) {
+	column, ok := forumSortColumns[sort]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown sort method '%s'", consts.ErrBadRequest, sort)
+	}
+	conditions := []string{"true"}
+	params := make([]interface{}, 0)
+	if owner != "" {
+		params = append(params, owner)
+		conditions = append(conditions, fmt.Sprintf(`"user" = $%d`, len(params)))
+	}
+	if since != "" {
+		params = append(params, since)
+		conditions = append(conditions, fmt.Sprintf(
+			"(%s, slug) %s (select %s, slug from forum where slug = $%d)",
+			column, r.getSinceOperator(desc), column, len(params),
+		))
+	}
+	if after != nil {
+		key, err := after.KeyString(0)
+		if err != nil {
+			return nil, err
+		}
+		params = append(params, key, after.ID)
+		conditions = append(conditions, fmt.Sprintf(
+			"(%s, slug) %s ($%d::%s, $%d)",
+			column, r.getSinceOperator(desc), len(params)-1, forumSortTypes[column], len(params),
+		))
+	}
+	query := fmt.Sprintf(
+		"select * from forum where %s order by %s %s, slug %s %s",
+		strings.Join(conditions, " and "), column, r.getOrder(desc), r.getOrder(desc), r.getLimit(limit),
+	)
+	forums := make(model.Forums, 0)
+	err := r.db.Select(&forums, query, params...)
+	return forums, err
+}
+
 func (r *Repository) GetForumUsers(forumSlug, since string, limit int, desc bool) (model.Users, error) {
 	forum, err := r.GetForumSlug(forumSlug)
 	if err != nil {
@@ -76,16 +182,70 @@ func (r *Repository) GetForumUsers(forumSlug, since string, limit int, desc bool
 	return users, err
 }
 
-func (r *Repository) countForumPosts(forumSlug string) (int, error) {
-	var count int
-	err := r.db.Get(&count, `select count(*) from post where forum=$1`, forumSlug)
+// checkForumsWritable refuses changes to archived forums. Like checkThreadWritable it locks
+// the forum rows until the transaction ends; they are locked in slug order, after any thread.
+func (r *Repository) checkForumsWritable(tx *sqlx.Tx, slugs ...string) error {
+	query, args, err := sqlx.In(`select slug, "isArchived" from forum where slug in (?) order by slug for no key update`, slugs)
 	if err != nil {
-		return 0, err
+		return err
+	}
+	forums := make(model.Forums, 0, len(slugs))
+	if err := tx.Select(&forums, tx.Rebind(query), args...); err != nil {
+		return err
+	}
+	if len(forums) == 0 {
+		return consts.ErrNotFound
+	}
+	for _, forum := range forums {
+		if forum.IsArchived {
+			return fmt.Errorf("%w: forum '%s' is archived", consts.ErrForbidden, forum.Slug)
+		}
+	}
+	return nil
+}
+
+func (r *Repository) addForumPostsCount(tx *sqlx.Tx, forumSlug string, delta int) error {
+	_, err := tx.Exec(`update forum set posts = posts + $1 where slug = $2`, delta, forumSlug)
+	return err
+}
+
+func (r *Repository) addForumCounts(tx *sqlx.Tx, forumSlug string, threads, posts int) error {
+	_, err := tx.Exec(
+		`update forum set threads = threads + $1, posts = posts + $2 where slug = $3`,
+		threads, posts, forumSlug,
+	)
+	return err
+}
+
+func (r *Repository) addForumUsers(tx *sqlx.Tx, forumSlug string, users []string) error {
+	for _, user := range users {
+		_, err := tx.Exec(
+			`insert into forum_user (forum, "user") values ($1, $2) on conflict do nothing`,
+			forumSlug, user,
+		)
+		if err != nil {
+			return err
+		}
 	}
-	return count, nil
+	return nil
 }
 
-func (r *Repository) updateForumPostsCount(id, posts int) error {
-	_, err := r.db.Exec(`update forum set posts=$1 where id=$2`, posts, id)
+// removeInactiveForumUsers drops users from a forum's member list once they have no thread and
+// no post left in it. Deleted posts do not count: their text and author are hidden.
+func (r *Repository) removeInactiveForumUsers(tx *sqlx.Tx, forumSlug string, users []string) error {
+	if len(users) == 0 {
+		return nil
+	}
+	query, args, err := sqlx.In(
+		`delete from forum_user where forum = ? and "user" in (?)
+			and not exists (select 1 from thread where thread.forum = forum_user.forum and thread.author = forum_user."user")
+			and not exists (select 1 from post where post.forum = forum_user.forum and post.author = forum_user."user"
+				and not post."isDeleted")`,
+		forumSlug, users,
+	)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(tx.Rebind(query), args...)
 	return err
 }
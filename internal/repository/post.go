@@ -3,8 +3,8 @@ package repository
 import (
 	"fmt"
 	"github.com/jmoiron/sqlx"
+	"project/internal/consts"
 	"project/internal/model"
-	"strconv"
 	"strings"
 	"time"
 )
@@ -14,17 +14,13 @@ const (
 	SortTree       = "tree"
 	SortParentTree = "parent_tree"
 
-	pathDelim    = "."
-	maxIDLength  = 7
-	maxTreeLevel = 5
-
 	postChunkSize = 50
-)
 
-var zeroPathStud = strings.Repeat("0", maxIDLength)
+	postThreadVisible = `not exists (select 1 from thread where thread.id = post.thread and thread."isDeleted")`
+)
 
 func (r *Repository) GetPostByID(id int) (*model.Post, error) {
-	return r.getPost("id=$1", id)
+	return r.getPost("id=$1 and "+postThreadVisible, id)
 }
 
 func (r *Repository) getPost(filter string, params ...interface{}) (*model.Post, error) {
@@ -37,17 +33,18 @@ func (r *Repository) getPostFields(fields, filter string, params ...interface{})
 	if err != nil {
 		return nil, Error(err)
 	}
+	r.hideDeletedPosts(&p)
 	return &p, nil
 }
 
-func (r *Repository) getPostsByIDs(ids []int) (model.Posts, error) {
+func (r *Repository) getPostsByIDs(tx *sqlx.Tx, ids []int) (model.Posts, error) {
 	posts := make(model.Posts, 0)
 	query, args, err := sqlx.In(`select * from post where id in (?) order by id`, ids)
 	if err != nil {
 		return nil, err
 	}
-	query = r.db.Rebind(query)
-	err = r.db.Select(&posts, query, args...)
+	query = tx.Rebind(query)
+	err = tx.Select(&posts, query, args...)
 	return posts, err
 }
 
@@ -58,22 +55,70 @@ func (r *Repository) getPosts(orderBy []string, limit int, filter string, params
 	}
 	posts := make(model.Posts, 0, limit)
 	err := r.db.Select(&posts, query, params...)
+	r.hideDeletedPosts(posts...)
 	return posts, err
 }
 
+func (r *Repository) hideDeletedPosts(posts ...*model.Post) {
+	for _, post := range posts {
+		if post.IsDeleted {
+			post.Author = ""
+			post.Message = ""
+		}
+	}
+}
+
 func (r *Repository) CreatePosts(posts []*model.PostCreate, thread *model.Thread) (model.Posts, error) {
 	forum, err := r.GetForumSlug(thread.Forum)
 	if err != nil {
 		return nil, err
 	}
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	authors, err := r.getUserNicknames(tx, posts)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := r.checkThreadWritable(tx, thread.ID, true); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := r.checkForumsWritable(tx, thread.Forum); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	result, err := r.createPosts(tx, forum, thread, posts, authors)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	return result, tx.Commit()
+}
+
+func (r *Repository) createPosts(tx *sqlx.Tx, forum *model.Forum, thread *model.Thread, posts []*model.PostCreate, authors map[string]string) (model.Posts, error) {
+	parents, err := r.checkPostsCreate(tx, posts, authors, thread.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.addForumPostsCount(tx, thread.Forum, len(posts)); err != nil {
+		return nil, err
+	}
 	now := time.Now()
+	if len(posts) > 0 {
+		if err := r.addThreadPosts(tx, thread.ID, len(posts), now, posts[len(posts)-1].Author); err != nil {
+			return nil, err
+		}
+	}
 	result := make(model.Posts, 0, len(posts))
 	for _, chunk := range r.chunkPosts(posts) {
-		createdIDs, err := r.createPostsChunk(forum, thread, chunk, now)
+		createdIDs, err := r.createPostsChunk(tx, forum, thread, chunk, parents, now)
 		if err != nil {
 			return nil, err
 		}
-		created, err := r.getPostsByIDs(createdIDs)
+		created, err := r.getPostsByIDs(tx, createdIDs)
 		if err != nil {
 			return nil, err
 		}
@@ -82,6 +127,130 @@ func (r *Repository) CreatePosts(posts []*model.PostCreate, thread *model.Thread
 	return result, nil
 }
 
+func (r *Repository) checkPostsCreate(tx *sqlx.Tx, posts []*model.PostCreate, authors map[string]string, threadID int) (map[int]*model.Post, error) {
+	parents, err := r.getPostParents(tx, posts)
+	if err != nil {
+		return nil, err
+	}
+	for i, post := range posts {
+		if err := r.checkPostCreate(post, threadID, authors, parents); err != nil {
+			return nil, fmt.Errorf("post %d: %w", i, err)
+		}
+		post.Author = authors[strings.ToLower(post.Author)]
+	}
+	return parents, nil
+}
+
+func (r *Repository) checkPostCreate(post *model.PostCreate, threadID int, authors map[string]string, parents map[int]*model.Post) error {
+	if _, ok := authors[strings.ToLower(post.Author)]; !ok {
+		return consts.ErrNotFound
+	}
+	if post.Parent != 0 {
+		parent, ok := parents[post.Parent]
+		if !ok {
+			return fmt.Errorf("%w: post parent do not exists", consts.ErrConflict)
+		}
+		if parent.Thread != threadID {
+			return fmt.Errorf("%w: parent post was created in another thread", consts.ErrConflict)
+		}
+	}
+	return nil
+}
+
+// getUserNicknames resolves post authors, by current or former nickname, inside the posting
+// transaction and without the user cache: another instance may have renamed or deleted a
+// cached user. The users stay locked until the posts are in, so a rename or delete waits and
+// then rewrites them too. Callers resolve authors before locking the thread, which a rename
+// also updates.
+func (r *Repository) getUserNicknames(tx *sqlx.Tx, posts []*model.PostCreate) (map[string]string, error) {
+	nicknames := make(map[string]string)
+	requested := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, post := range posts {
+		key := strings.ToLower(post.Author)
+		if !seen[key] {
+			seen[key] = true
+			requested = append(requested, post.Author)
+		}
+	}
+	if len(requested) == 0 {
+		return nicknames, nil
+	}
+	query, args, err := sqlx.In(
+		`select distinct on (requested) id, nickname, requested from (
+			select id, nickname, nickname as requested, 0 as source from "user" where nickname in (?)
+			union all
+			select "user".id, "user".nickname, user_alias.nickname, 1 from user_alias
+				join "user" on "user".nickname = user_alias.current
+				where user_alias.nickname in (?)
+		) found order by requested, source`,
+		requested, requested,
+	)
+	if err != nil {
+		return nil, err
+	}
+	var found []struct {
+		model.User
+		Requested string `db:"requested"`
+	}
+	if err := tx.Select(&found, tx.Rebind(query), args...); err != nil {
+		return nil, err
+	}
+	if len(found) == 0 {
+		return nicknames, nil
+	}
+	ids := make([]int, 0, len(found))
+	for _, user := range found {
+		ids = append(ids, user.ID)
+	}
+	query, args, err = sqlx.In(`select id, nickname from "user" where id in (?) order by id for share`, ids)
+	if err != nil {
+		return nil, err
+	}
+	var locked []model.User
+	if err := tx.Select(&locked, tx.Rebind(query), args...); err != nil {
+		return nil, err
+	}
+	current := make(map[int]string, len(locked))
+	for _, user := range locked {
+		current[user.ID] = user.Nickname
+	}
+	for _, user := range found {
+		if nickname, ok := current[user.ID]; ok {
+			nicknames[strings.ToLower(user.Requested)] = nickname
+		}
+	}
+	return nicknames, nil
+}
+
+func (r *Repository) getPostParents(tx *sqlx.Tx, posts []*model.PostCreate) (map[int]*model.Post, error) {
+	parents := make(map[int]*model.Post)
+	ids := make([]int, 0)
+	seen := make(map[int]bool)
+	for _, post := range posts {
+		if post.Parent == 0 || seen[post.Parent] {
+			continue
+		}
+		seen[post.Parent] = true
+		ids = append(ids, post.Parent)
+	}
+	if len(ids) == 0 {
+		return parents, nil
+	}
+	query, args, err := sqlx.In(`select id, thread, path from post where id in (?) for share`, ids)
+	if err != nil {
+		return nil, err
+	}
+	var found model.Posts
+	if err := tx.Select(&found, tx.Rebind(query), args...); err != nil {
+		return nil, err
+	}
+	for _, parent := range found {
+		parents[parent.ID] = parent
+	}
+	return parents, nil
+}
+
 func (r *Repository) chunkPosts(posts []*model.PostCreate) [][]*model.PostCreate {
 	chunked := make([][]*model.PostCreate, 0)
 	for i := 0; i < len(posts); i += postChunkSize {
@@ -94,17 +263,17 @@ func (r *Repository) chunkPosts(posts []*model.PostCreate) [][]*model.PostCreate
 	return chunked
 }
 
-func (r *Repository) createPostsChunk(forum *model.Forum, thread *model.Thread, posts []*model.PostCreate, created time.Time) ([]int, error) {
+func (r *Repository) createPostsChunk(tx *sqlx.Tx, forum *model.Forum, thread *model.Thread, posts []*model.PostCreate, parents map[int]*model.Post, created time.Time) ([]int, error) {
 	columns := 8
 	placeholders := make([]string, 0, len(posts))
 	args := make([]interface{}, 0, len(posts)*columns)
-	ids := r.postsIDGenerator.Next(len(posts))
+	ids, err := r.postsIDGenerator.Next(len(posts))
+	if err != nil {
+		return nil, err
+	}
 	for i, post := range posts {
 		id := ids[i]
-		path, err := r.getPostPath(id, post.Parent)
-		if err != nil {
-			return nil, err
-		}
+		path := r.getPostPath(id, parents[post.Parent])
 		args = append(args, id, thread.ID, thread.Forum, post.Parent, path, post.Author, post.Message, created)
 		placeholders = append(placeholders, fmt.Sprintf(
 			"($%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d)",
@@ -115,51 +284,163 @@ func (r *Repository) createPostsChunk(forum *model.Forum, thread *model.Thread,
 		"insert into post (id, thread, forum, parent, path, author, message, created) values %s",
 		strings.Join(placeholders, ","),
 	)
-	_, err := r.db.Exec(query, args...)
+	_, err = tx.Exec(query, args...)
 	return ids, err
 }
 
-func (r *Repository) getPostPath(id, parentID int) (string, error) {
-	var base string
-	if parentID == 0 {
-		base = r.getZeroPostPath()
-	} else {
-		parent, err := r.getPostFields("path", "id=$1", parentID)
+func (r *Repository) getPostPath(id int, parent *model.Post) model.PostPath {
+	if parent == nil {
+		return model.PostPath{id}
+	}
+	return parent.Path.Child(id)
+}
+
+func (r *Repository) UpdatePostMessage(id int, message, editor string) (*model.Post, error) {
+	if message != "" {
+		tx, err := r.db.Beginx()
 		if err != nil {
-			return "", err
+			return nil, err
+		}
+		if err := r.updatePostMessage(tx, id, message, editor); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, err
 		}
-		base = parent.Path
 	}
-	path := strings.Replace(base, zeroPathStud, r.padPostID(id), 1)
-	return path, nil
+	return r.GetPostByID(id)
 }
 
-func (r *Repository) getZeroPostPath() string {
-	path := zeroPathStud
-	for i := 0; i < maxTreeLevel-1; i++ {
-		path += pathDelim + zeroPathStud
+func (r *Repository) updatePostMessage(tx *sqlx.Tx, id int, message, editor string) error {
+	current := model.Post{}
+	if err := tx.Get(&current, `select "message", thread, "isDeleted" from post where id = $1 and `+postThreadVisible+` for update`, id); err != nil {
+		return Error(err)
+	}
+	if err := r.checkThreadWritable(tx, current.Thread, false); err != nil {
+		return err
+	}
+	if current.IsDeleted {
+		return fmt.Errorf("%w: post is deleted", consts.ErrConflict)
 	}
-	return path
+	if current.Message == message {
+		return nil
+	}
+	if err := r.addPostRevision(tx, id, message, editor); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`update post set "message" = $1, "isEdited" = true where id = $2`, message, id)
+	return err
 }
 
-func (r *Repository) padPostID(id int) string {
-	return fmt.Sprintf("%0"+strconv.Itoa(maxIDLength)+"d", id)
+func (r *Repository) DeletePost(id int) (*model.Post, error) {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	if err := r.deletePost(tx, id); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return r.GetPostByID(id)
 }
 
-func (r *Repository) UpdatePostMessage(id int, message string) (*model.Post, error) {
-	if message != "" {
-		_, err := r.db.Exec(
-			`update post set "message" = $1, "isEdited" = true where id = $2 and "message" <> $1`,
-			message, id,
-		)
-		if err != nil {
-			return nil, err
-		}
+func (r *Repository) deletePost(tx *sqlx.Tx, id int) error {
+	post := model.Post{}
+	if err := tx.Get(&post, `select forum, thread, author, "isDeleted" from post where id = $1 and `+postThreadVisible+` for update`, id); err != nil {
+		return Error(err)
 	}
-	return r.GetPostByID(id)
+	if err := r.checkThreadWritable(tx, post.Thread, false); err != nil {
+		return err
+	}
+	if post.IsDeleted {
+		return nil
+	}
+	if _, err := tx.Exec(`update post set "isDeleted" = true where id = $1`, id); err != nil {
+		return err
+	}
+	if err := r.refreshThreadPosts(tx, post.Thread); err != nil {
+		return err
+	}
+	if err := r.removeInactiveForumUsers(tx, post.Forum, []string{post.Author}); err != nil {
+		return err
+	}
+	return r.addForumPostsCount(tx, post.Forum, -1)
+}
+
+func (r *Repository) PurgePost(id int) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return err
+	}
+	if err := r.purgePost(tx, id); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *Repository) purgePost(tx *sqlx.Tx, id int) error {
+	post := model.Post{}
+	if err := tx.Get(&post, `select forum, thread, author, "isDeleted" from post where id = $1 and `+postThreadVisible+` for update`, id); err != nil {
+		return Error(err)
+	}
+	if err := r.checkThreadWritable(tx, post.Thread, false); err != nil {
+		return err
+	}
+	var hasReplies bool
+	if err := tx.Get(&hasReplies, `select exists(select 1 from post where parent = $1)`, id); err != nil {
+		return err
+	}
+	if hasReplies {
+		return fmt.Errorf("%w: only posts without replies can be purged", consts.ErrConflict)
+	}
+	if _, err := tx.Exec(`delete from post_revision where post = $1`, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`delete from post where id = $1`, id); err != nil {
+		return err
+	}
+	if post.IsDeleted {
+		return nil
+	}
+	if err := r.removeInactiveForumUsers(tx, post.Forum, []string{post.Author}); err != nil {
+		return err
+	}
+	if err := r.refreshThreadPosts(tx, post.Thread); err != nil {
+		return err
+	}
+	return r.addForumPostsCount(tx, post.Forum, -1)
 }
 
-func (r *Repository) updatePostPath(tx *sqlx.Tx, id int, path string) error {
+func (r *Repository) updatePostPath(tx *sqlx.Tx, id int, path model.PostPath) error {
 	_, err := tx.Exec(`update post set path = $1 where id = $2`, path, id)
 	return err
 }
+
+func (r *Repository) CountPostsReplies(ids []int) (map[int]int, error) {
+	replies := make(map[int]int, len(ids))
+	if len(ids) == 0 {
+		return replies, nil
+	}
+	query, args, err := sqlx.In(`select parent, count(*) from post where parent in (?) group by parent`, ids)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := r.db.Query(r.db.Rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var parent, count int
+		if err := rows.Scan(&parent, &count); err != nil {
+			return nil, err
+		}
+		replies[parent] = count
+	}
+	return replies, rows.Err()
+}
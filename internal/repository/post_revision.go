@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"project/internal/consts"
+	"project/internal/model"
+)
+
+func (r *Repository) GetPostRevisions(id int) ([]*model.PostRevision, error) {
+	post, err := r.GetPostByID(id)
+	if err != nil {
+		return nil, err
+	}
+	revisions := make([]*model.PostRevision, 0)
+	err = r.db.Select(&revisions,
+		`select post_revision.post, revision, post_revision.message, editor, post_revision.created
+			from post_revision join post on post.id = post_revision.post
+			where post_revision.post = $1 and not post."isDeleted" order by revision`,
+		id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return r.getVisibleRevisions(post, revisions)
+}
+
+// getVisibleRevisions hides the history of tombstoned posts, whose old text must not leak,
+// and stands in the post itself as revision 0 for posts that were never edited.
+func (r *Repository) getVisibleRevisions(post *model.Post, revisions []*model.PostRevision) ([]*model.PostRevision, error) {
+	if post.IsDeleted {
+		return nil, fmt.Errorf("%w: post is deleted", consts.ErrNotFound)
+	}
+	if len(revisions) > 0 {
+		return revisions, nil
+	}
+	original := model.PostRevision{
+		Post:    post.ID,
+		Message: post.Message,
+		Editor:  post.Author,
+		Created: post.Created,
+	}
+	return []*model.PostRevision{&original}, nil
+}
+
+func (r *Repository) addPostRevision(tx *sqlx.Tx, id int, message, editor string) error {
+	_, err := tx.Exec(
+		`insert into post_revision (post, revision, message, editor, created)
+			select id, 0, message, author, created from post
+			where id = $1 and not exists (select 1 from post_revision where post = $1)`,
+		id,
+	)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(
+		`insert into post_revision (post, revision, message, editor, created)
+			select post, max(revision) + 1, $2, $3, now() from post_revision where post = $1 group by post`,
+		id, message, editor,
+	)
+	return err
+}
@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"errors"
+	"project/internal/consts"
+	"project/internal/model"
+	"strings"
+	"testing"
+)
+
+func TestGetVisibleRevisionsHidesDeletedPostHistory(t *testing.T) {
+	r := &Repository{}
+	post := &model.Post{ID: 1, Author: "", Message: "", IsDeleted: true}
+	revisions := []*model.PostRevision{
+		{Post: 1, Revision: 0, Message: "first secret", Editor: "alice"},
+		{Post: 1, Revision: 1, Message: "second secret", Editor: "bob"},
+	}
+	got, err := r.getVisibleRevisions(post, revisions)
+	if !errors.Is(err, consts.ErrNotFound) {
+		t.Fatalf("error = %v, want %v", err, consts.ErrNotFound)
+	}
+	if len(got) != 0 {
+		t.Fatalf("got %d revisions for a deleted post, want none", len(got))
+	}
+	if strings.Contains(err.Error(), "secret") {
+		t.Errorf("error %q leaks old post text", err)
+	}
+}
+
+func TestGetVisibleRevisions(t *testing.T) {
+	r := &Repository{}
+	post := &model.Post{ID: 1, Author: "alice", Message: "current", Created: "2020-01-01T00:00:00Z"}
+
+	got, err := r.getVisibleRevisions(post, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != 1 || got[0].Revision != 0 || got[0].Message != "current" || got[0].Editor != "alice" {
+		t.Errorf("unedited post revisions = %+v, want the post as revision 0", got)
+	}
+
+	revisions := []*model.PostRevision{
+		{Post: 1, Revision: 0, Message: "original", Editor: "alice"},
+		{Post: 1, Revision: 1, Message: "current", Editor: "bob"},
+	}
+	got, err = r.getVisibleRevisions(post, revisions)
+	if err != nil || len(got) != 2 {
+		t.Errorf("edited post revisions = %+v, %v, want the stored history", got, err)
+	}
+}
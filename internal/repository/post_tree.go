@@ -0,0 +1,40 @@
+package repository
+
+import (
+	"fmt"
+	"project/internal/model"
+	"strings"
+)
+
+func (r *Repository) GetPostReplies(id, limit int, since *int, depth int, desc bool) (model.Posts, error) {
+	post, err := r.getPostFields("id, thread, path", "id=$1 and "+postThreadVisible, id)
+	if err != nil {
+		return nil, err
+	}
+	conditions := []string{"thread = $1", "path > $2", "path < $3"}
+	params := []interface{}{post.Thread, post.Path, post.Path.UpperBound()}
+	if depth > 0 {
+		conditions = append(conditions, fmt.Sprintf("array_length(path, 1) <= %d", len(post.Path)+depth))
+	}
+	if since != nil {
+		sinceCond, err := r.getSinceCondition(since, desc)
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, sinceCond)
+	}
+	orderBy := []string{"path " + r.getOrder(desc)}
+	return r.getPosts(orderBy, limit, strings.Join(conditions, " and "), params...)
+}
+
+func (r *Repository) GetPostAncestors(id int) (model.Posts, error) {
+	post, err := r.getPostFields("id, thread, path", "id=$1 and "+postThreadVisible, id)
+	if err != nil {
+		return nil, err
+	}
+	ancestors := post.Path.Ancestors()
+	if len(ancestors) == 0 {
+		return make(model.Posts, 0), nil
+	}
+	return r.getPosts([]string{"path"}, 0, "thread = $1 and id = any($2)", post.Thread, ancestors)
+}
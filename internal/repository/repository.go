@@ -7,17 +7,19 @@ import (
 	"project/internal/generator"
 )
 
+const postIDSequence = "post_id_seq"
+
 type Repository struct {
 	db               *sqlx.DB
 	users            cache.UserCache
-	postsIDGenerator generator.Generator
+	postsIDGenerator *generator.Generator
 }
 
 func NewRepository(db *sqlx.DB) Repository {
 	return Repository{
 		db:               db,
 		users:            cache.NewUserCache(),
-		postsIDGenerator: generator.NewGenerator(),
+		postsIDGenerator: generator.NewGenerator(db, postIDSequence),
 	}
 }
 
@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"fmt"
+	"project/internal/consts"
+	"project/internal/model"
+	"strconv"
+	"strings"
+)
+
+const (
+	SearchPosts   = "post"
+	SearchThreads = "thread"
+
+	searchConfig   = "simple"
+	postDocument   = "to_tsvector('" + searchConfig + "', post.message)"
+	threadDocument = "to_tsvector('" + searchConfig + "', thread.title || ' ' || thread.message)"
+	searchHeadline = "StartSel=<mark>, StopSel=</mark>, MaxFragments=2"
+)
+
+// Search ranks matching posts and threads, best first, paging after the rank, kind and id a
+// cursor recorded. Before cursors select the hits just above the cursor instead.
+func (r *Repository) Search(filter model.SearchFilter, after *model.Cursor, limit int) ([]*model.SearchHit, error) {
+	params := []interface{}{filter.Query}
+	bind := func(value interface{}) string {
+		params = append(params, value)
+		return "$" + strconv.Itoa(len(params))
+	}
+	postConds := []string{postDocument + " @@ q.query", `not post."isDeleted"`, postThreadVisible}
+	threadConds := []string{threadDocument + " @@ q.query", `not thread."isDeleted"`}
+	if filter.Forum != "" {
+		p := bind(filter.Forum)
+		postConds = append(postConds, "post.forum = "+p)
+		threadConds = append(threadConds, "thread.forum = "+p)
+	}
+	if filter.Thread != 0 {
+		p := bind(filter.Thread)
+		postConds = append(postConds, "post.thread = "+p)
+		threadConds = append(threadConds, "thread.id = "+p)
+	}
+	if filter.Author != "" {
+		p := bind(filter.Author)
+		postConds = append(postConds, "post.author::citext = "+p+"::citext")
+		threadConds = append(threadConds, "thread.author::citext = "+p+"::citext")
+	}
+	if filter.From != "" {
+		p := bind(filter.From)
+		postConds = append(postConds, "post.created >= "+p+"::timestamptz")
+		threadConds = append(threadConds, "thread.created >= "+p+"::timestamptz")
+	}
+	if filter.To != "" {
+		p := bind(filter.To)
+		postConds = append(postConds, "post.created < "+p+"::timestamptz")
+		threadConds = append(threadConds, "thread.created < "+p+"::timestamptz")
+	}
+	branches := make([]string, 0, 2)
+	if filter.Type == "" || filter.Type == SearchPosts {
+		branches = append(branches, fmt.Sprintf(
+			`select 'post' as kind, post.id, post.thread, post.forum, post.author, post.created,
+				'' as title, post.message as body, ts_rank(%s, q.query) as rank
+			from post, q where %s`,
+			postDocument, strings.Join(postConds, " and "),
+		))
+	}
+	if filter.Type == "" || filter.Type == SearchThreads {
+		branches = append(branches, fmt.Sprintf(
+			`select 'thread' as kind, thread.id, thread.id, thread.forum, thread.author, thread.created,
+				thread.title, thread.message, ts_rank(%s, q.query)
+			from thread, q where %s`,
+			threadDocument, strings.Join(threadConds, " and "),
+		))
+	}
+	if len(branches) == 0 {
+		return nil, fmt.Errorf("%w: unknown search type '%s'", consts.ErrBadRequest, filter.Type)
+	}
+	afterFilter := ""
+	before := after != nil && after.Before
+	if after != nil {
+		rank, err := after.KeyFloat(0)
+		if err != nil {
+			return nil, err
+		}
+		kind, err := after.KeyString(1)
+		if err != nil {
+			return nil, err
+		}
+		id, err := after.IntID()
+		if err != nil {
+			return nil, err
+		}
+		afterFilter = fmt.Sprintf(
+			"where (rank, kind, id) %s (%s::real, %s, %s::bigint)",
+			r.getSinceOperator(!before), bind(rank), bind(kind), bind(*id),
+		)
+	}
+	query := fmt.Sprintf(
+		`with q as (select plainto_tsquery('%s', $1) as query),
+		hits as (%s)
+		select kind, id, thread, forum, author, created, title, rank,
+			ts_headline('%s', %s, (select query from q), '%s') as snippet
+		from (select * from hits %s order by rank %s, kind %s, id %s %s) page
+		order by rank desc, kind desc, id desc`,
+		searchConfig, strings.Join(branches, " union all "),
+		searchConfig, r.escapeHTML("body"), searchHeadline, afterFilter,
+		r.getOrder(!before), r.getOrder(!before), r.getOrder(!before), r.getLimit(limit),
+	)
+	hits := make([]*model.SearchHit, 0)
+	err := r.db.Select(&hits, query, params...)
+	return hits, err
+}
+
+// escapeHTML escapes markup in user text, so the highlight marks are the only tags in a snippet.
+func (r *Repository) escapeHTML(column string) string {
+	return fmt.Sprintf(
+		`replace(replace(replace(replace(replace(%s, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'), '"', '&quot;'), '''', '&#39;')`,
+		column,
+	)
+}
@@ -2,36 +2,177 @@ package repository
 
 import (
 	"fmt"
+	"github.com/jmoiron/sqlx"
+	"project/internal/consts"
 	"project/internal/model"
 	"strconv"
+	"strings"
+	"time"
 )
 
-func (r *Repository) GetForumThreads(forum string, limit int, desc bool) (model.Threads, error) {
+const (
+	ThreadSortCreated = "created"
+	ThreadSortHot     = "hot"
+	ThreadSortTop     = "top"
+	ThreadSortActive  = "active"
+
+	threadVisible = `not "isDeleted"`
+	threadDeleted = `"isDeleted"`
+	threadAny     = `true`
+)
+
+// GetForumPinnedThreads pages the pinned threads shown ahead of every ordering of a forum's
+// thread list, newest first, after the position a cursor recorded among them.
+// reverse walks back up from the cursor, or up from the last pinned thread without one.
+func (r *Repository) GetForumPinnedThreads(forum string, archived bool, after *model.Cursor, limit int, reverse bool) (model.Threads, error) {
+	conditions := []string{"forum = $1", `"isPinned"`, r.getThreadListFilter(archived)}
+	params := []interface{}{forum}
+	if after != nil {
+		keyset, keyParams, err := r.getThreadKeyset("created", r.getSinceOperator(!reverse), *after, len(params))
+		if err != nil {
+			return nil, err
+		}
+		params = append(params, keyParams...)
+		conditions = append(conditions, keyset)
+	}
 	query := fmt.Sprintf(
-		"select * from thread where forum = $1 order by created %s limit $2",
-		r.getOrder(desc),
+		"select * from thread where %s order by created %s, id %s %s",
+		strings.Join(conditions, " and "), r.getOrder(!reverse), r.getOrder(!reverse), r.getLimit(limit),
+	)
+	threads := make(model.Threads, 0)
+	err := r.db.Select(&threads, query, params...)
+	return threads, err
+}
+
+func (r *Repository) GetForumThreads(forum string, archived bool, limit int, desc bool) (model.Threads, error) {
+	query := fmt.Sprintf(
+		"select * from thread where forum = $1 and not \"isPinned\" and %s order by created %s, id %s limit $2",
+		r.getThreadListFilter(archived), r.getOrder(desc), r.getOrder(desc),
 	)
 	var threads model.Threads
 	err := r.db.Select(&threads, query, forum, limit)
 	return threads, err
 }
 
-func (r *Repository) GetForumThreadsSince(forum, since string, limit int, desc bool) (model.Threads, error) {
+func (r *Repository) GetForumThreadsSince(forum, since string, archived bool, limit int, desc bool) (model.Threads, error) {
 	createdCond := ">="
 	if desc {
 		createdCond = "<="
 	}
 	query := fmt.Sprintf(
-		"select * from thread where forum = $1 and created %s $2 order by created %s limit $3",
-		createdCond, r.getOrder(desc),
+		"select * from thread where forum = $1 and not \"isPinned\" and %s and created %s $2 order by created %s, id %s limit $3",
+		r.getThreadListFilter(archived), createdCond, r.getOrder(desc), r.getOrder(desc),
 	)
 	threads := make(model.Threads, 0)
 	err := r.db.Select(&threads, query, forum, since, limit)
 	return threads, err
 }
 
+// GetForumThreadsAfter pages by the creation time a cursor recorded, breaking ties by id.
+func (r *Repository) GetForumThreadsAfter(forum string, after model.Cursor, archived bool, limit int, desc bool) (model.Threads, error) {
+	keyset, params, err := r.getThreadKeyset("created", r.getSinceOperator(desc), after, 1)
+	if err != nil {
+		return nil, err
+	}
+	query := fmt.Sprintf(
+		`select * from thread where forum = $1 and not "isPinned" and %s and %s
+		order by created %s, id %s %s`,
+		r.getThreadListFilter(archived), keyset, r.getOrder(desc), r.getOrder(desc), r.getLimit(limit),
+	)
+	threads := make(model.Threads, 0)
+	err = r.db.Select(&threads, query, append([]interface{}{forum}, params...)...)
+	return threads, err
+}
+
+// GetForumThreadsRanked pages hot, top and active lists either after a cursor or,
+// for the legacy since parameter, after the current score of a thread id.
+// Ranked lists are always best first; reverse walks back up from the position instead.
+func (r *Repository) GetForumThreadsRanked(forum, sort, window string, since *int, after *model.Cursor, archived bool, limit int, reverse bool) (model.Threads, error) {
+	sortKeys := map[string]string{
+		ThreadSortHot:    "sign(votes) * log(greatest(abs(votes), 1)) + extract(epoch from created) / 45000",
+		ThreadSortTop:    "votes",
+		ThreadSortActive: "coalesce(last_post_at, created)",
+	}
+	key, ok := sortKeys[sort]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown sort method '%s'", consts.ErrBadRequest, sort)
+	}
+	conditions := []string{"forum = $1", `not "isPinned"`, r.getThreadListFilter(archived)}
+	params := []interface{}{forum}
+	if sort == ThreadSortTop && window != "" {
+		windows := map[string]string{"day": "1 day", "week": "7 days", "month": "1 month", "year": "1 year", "all": ""}
+		interval, ok := windows[window]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown time window '%s'", consts.ErrBadRequest, window)
+		}
+		if interval != "" {
+			params = append(params, interval)
+			conditions = append(conditions, fmt.Sprintf("created >= now() - $%d::interval", len(params)))
+		}
+	}
+	switch {
+	case after != nil:
+		keyset, keyParams, err := r.getThreadKeyset(key, r.getSinceOperator(!reverse), *after, len(params))
+		if err != nil {
+			return nil, err
+		}
+		params = append(params, keyParams...)
+		conditions = append(conditions, keyset)
+	case since != nil:
+		params = append(params, *since)
+		conditions = append(conditions, fmt.Sprintf(
+			"(%s, id) %s (select %s, id from thread where id = $%d)", key, r.getSinceOperator(!reverse), key, len(params),
+		))
+	}
+	query := fmt.Sprintf(
+		"select * from thread where %s order by %s %s, id %s %s",
+		strings.Join(conditions, " and "), key, r.getOrder(!reverse), r.getOrder(!reverse), r.getLimit(limit),
+	)
+	threads := make(model.Threads, 0)
+	err := r.db.Select(&threads, query, params...)
+	return threads, err
+}
+
+// getThreadKeyset compares a thread ordering key with the one a model.ThreadCursor recorded.
+// The key expression is evaluated over the recorded values, so it reads the same columns
+// as for thread rows. Parameters are numbered after the first bound ones.
+func (r *Repository) getThreadKeyset(key, operator string, at model.Cursor, bound int) (string, []interface{}, error) {
+	votes, err := at.KeyInt(0)
+	if err != nil {
+		return "", nil, err
+	}
+	created, err := at.KeyTime(1)
+	if err != nil {
+		return "", nil, err
+	}
+	lastPostAt, err := at.KeyTime(2)
+	if err != nil {
+		return "", nil, err
+	}
+	id, err := at.IntID()
+	if err != nil {
+		return "", nil, err
+	}
+	keyset := fmt.Sprintf(
+		`(%s, id) %s (
+			(select %s from (values ($%d::int, $%d::timestamptz, $%d::timestamptz)) as cursor (votes, created, last_post_at)),
+			$%d::int
+		)`,
+		key, operator, key, bound+1, bound+2, bound+3, bound+4,
+	)
+	return keyset, []interface{}{votes, created, lastPostAt, *id}, nil
+}
+
+// getThreadListFilter hides deleted threads from forum listings, and archived ones unless asked for.
+func (r *Repository) getThreadListFilter(archived bool) string {
+	if archived {
+		return threadVisible
+	}
+	return threadVisible + ` and not "isArchived"`
+}
+
 func (r *Repository) GetThreadByID(id int) (*model.Thread, error) {
-	return r.getThread("*", "id=$1", id)
+	return r.getThread("*", "id=$1 and "+threadVisible, id)
 }
 
 func (r *Repository) GetThreadBySlug(slug string) (*model.Thread, error) {
@@ -43,11 +184,15 @@ func (r *Repository) GetThreadBySlugOrID(slugOrID string) (*model.Thread, error)
 }
 
 func (r *Repository) GetThreadFieldsBySlugOrID(fields, slugOrID string) (*model.Thread, error) {
+	return r.getThreadBySlugOrID(fields, slugOrID, threadVisible)
+}
+
+func (r *Repository) getThreadBySlugOrID(fields, slugOrID, filter string) (*model.Thread, error) {
 	id, err := strconv.Atoi(slugOrID)
 	if err != nil {
-		return r.getThread(fields, "slug=$1", slugOrID)
+		return r.getThread(fields, "slug=$1 and "+filter, slugOrID)
 	}
-	return r.getThread(fields, "id=$1", id)
+	return r.getThread(fields, "id=$1 and "+filter, id)
 }
 
 func (r *Repository) getThread(fields, filter string, params ...interface{}) (*model.Thread, error) {
@@ -60,33 +205,335 @@ func (r *Repository) getThread(fields, filter string, params ...interface{}) (*m
 }
 
 func (r *Repository) CreateThread(forum *model.Forum, thread model.ThreadCreate) (*model.Thread, error) {
-	var id int
-	err := r.db.
-		QueryRow(
-			`insert into thread (title, author, forum, message, slug, created) values ($1, $2, $3, $4, $5, $6) returning id`,
-			thread.Title, thread.Author, forum.Slug, thread.Message, thread.Slug, thread.Created,
-		).
-		Scan(&id)
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	id, err := r.createThread(tx, forum, thread)
 	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
 		return nil, err
 	}
 	return r.GetThreadByID(id)
 }
 
+func (r *Repository) createThread(tx *sqlx.Tx, forum *model.Forum, thread model.ThreadCreate) (int, error) {
+	if err := r.checkForumsWritable(tx, forum.Slug); err != nil {
+		return 0, err
+	}
+	var id int
+	err := tx.QueryRow(
+		`insert into thread (title, author, forum, message, slug, created) values ($1, $2, $3, $4, $5, $6) returning id`,
+		thread.Title, thread.Author, forum.Slug, thread.Message, thread.Slug, thread.Created,
+	).Scan(&id)
+	return id, err
+}
+
 func (r *Repository) UpdateThread(threadSlugOrID string, message, title string) (*model.Thread, error) {
-	thread, err := r.GetThreadBySlugOrID(threadSlugOrID)
+	thread, err := r.GetThreadFieldsBySlugOrID("id", threadSlugOrID)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	updated, err := r.updateThread(tx, thread.ID, message, title)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	return updated, tx.Commit()
+}
+
+// updateThread changes the given fields of a thread; empty ones are kept.
+// The forum is checked after the update, which returns the forum the locked thread is in.
+func (r *Repository) updateThread(tx *sqlx.Tx, id int, message, title string) (*model.Thread, error) {
+	if err := r.checkThreadWritable(tx, id, false); err != nil {
+		return nil, err
+	}
+	updated := model.Thread{}
+	err := tx.Get(
+		&updated,
+		`update thread set
+			"message" = coalesce(nullif($1, ''), "message"),
+			title = coalesce(nullif($2, ''), title)
+		where id = $3 returning *`,
+		message, title, id,
+	)
+	if err != nil {
+		return nil, Error(err)
+	}
+	if err := r.checkForumsWritable(tx, updated.Forum); err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// ModerateThread changes only the states given, in one statement, so concurrent
+// moderation of different states does not overwrite each other.
+func (r *Repository) ModerateThread(threadSlugOrID string, state model.ThreadModerate) (*model.Thread, error) {
+	thread, err := r.GetThreadFieldsBySlugOrID("id", threadSlugOrID)
+	if err != nil {
+		return nil, err
+	}
+	moderated := model.Thread{}
+	err = r.db.Get(
+		&moderated,
+		`update thread set
+			"isLocked" = coalesce($1, "isLocked"),
+			"isPinned" = coalesce($2, "isPinned"),
+			"isArchived" = coalesce($3, "isArchived")
+		where id = $4 and `+threadVisible+` returning *`,
+		state.IsLocked, state.IsPinned, state.IsArchived, thread.ID,
+	)
+	if err != nil {
+		return nil, Error(err)
+	}
+	return &moderated, nil
+}
+
+// checkThreadWritable refuses changes to an archived thread and, for new posts, to a locked one.
+// It locks the thread row until the transaction ends, so moderation can not slip in between.
+// The lock is the one the thread counter updates take later anyway: with a share lock,
+// two writers to the same thread would deadlock upgrading it.
+func (r *Repository) checkThreadWritable(tx *sqlx.Tx, threadID int, posting bool) error {
+	thread := model.Thread{}
+	err := tx.Get(&thread, `select "isLocked", "isArchived" from thread where id = $1 for no key update`, threadID)
+	if err != nil {
+		return Error(err)
+	}
+	if thread.IsArchived {
+		return fmt.Errorf("%w: thread is archived", consts.ErrForbidden)
+	}
+	if posting && thread.IsLocked {
+		return fmt.Errorf("%w: thread is locked", consts.ErrForbidden)
+	}
+	return nil
+}
+
+func (r *Repository) DeleteThread(slugOrID string) (*model.Thread, error) {
+	thread, err := r.getThreadBySlugOrID("*", slugOrID, threadVisible)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.setThreadDeleted(thread, true); err != nil {
+		return nil, err
+	}
+	return thread, nil
+}
+
+func (r *Repository) RestoreThread(slugOrID string) (*model.Thread, error) {
+	thread, err := r.getThreadBySlugOrID("*", slugOrID, threadDeleted)
 	if err != nil {
 		return nil, err
 	}
-	if message != "" {
-		thread.Message = message
+	if err := r.setThreadDeleted(thread, false); err != nil {
+		return nil, err
 	}
-	if title != "" {
-		thread.Title = title
+	return thread, nil
+}
+
+func (r *Repository) setThreadDeleted(thread *model.Thread, deleted bool) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return err
 	}
-	_, err = r.db.Exec(
-		`update thread set "message" = $1, title = $2 where id = $3`,
-		thread.Message, thread.Title, thread.ID,
+	if err := r.updateThreadDeleted(tx, thread, deleted); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	thread.IsDeleted = deleted
+	return nil
+}
+
+func (r *Repository) updateThreadDeleted(tx *sqlx.Tx, thread *model.Thread, deleted bool) error {
+	result, err := tx.Exec(
+		`update thread set "isDeleted" = $1 where id = $2 and "isDeleted" <> $1`,
+		deleted, thread.ID,
+	)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return consts.ErrNotFound
+	}
+	posts, err := r.countThreadPosts(tx, thread.ID)
+	if err != nil {
+		return err
+	}
+	delta := 1
+	if deleted {
+		delta = -1
+	}
+	return r.addForumCounts(tx, thread.Forum, delta, delta*posts)
+}
+
+func (r *Repository) PurgeThread(slugOrID string) error {
+	thread, err := r.getThreadBySlugOrID("id, forum", slugOrID, threadAny)
+	if err != nil {
+		return err
+	}
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return err
+	}
+	if err := r.purgeThread(tx, thread); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *Repository) purgeThread(tx *sqlx.Tx, thread *model.Thread) error {
+	var wasDeleted bool
+	err := tx.Get(&wasDeleted, `select "isDeleted" from thread where id = $1 for update`, thread.ID)
+	if err != nil {
+		return Error(err)
+	}
+	posts, err := r.countThreadPosts(tx, thread.ID)
+	if err != nil {
+		return err
+	}
+	var authors []string
+	err = tx.Select(&authors,
+		`select author from thread where id = $1 union select author from post where thread = $1`,
+		thread.ID,
+	)
+	if err != nil {
+		return err
+	}
+	queries := []string{
+		`delete from post_revision where post in (select id from post where thread = $1)`,
+		`delete from post where thread = $1`,
+		`delete from vote where thread = $1`,
+		`delete from thread where id = $1`,
+	}
+	for _, query := range queries {
+		if _, err := tx.Exec(query, thread.ID); err != nil {
+			return err
+		}
+	}
+	if !wasDeleted {
+		if err := r.addForumCounts(tx, thread.Forum, -1, -posts); err != nil {
+			return err
+		}
+	}
+	return r.removeInactiveForumUsers(tx, thread.Forum, authors)
+}
+
+func (r *Repository) countThreadPosts(tx *sqlx.Tx, threadID int) (int, error) {
+	var count int
+	err := tx.Get(&count, `select count(*) from post where thread = $1 and not "isDeleted"`, threadID)
+	return count, err
+}
+
+func (r *Repository) MoveThread(thread *model.Thread, forum *model.Forum) (*model.Thread, error) {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	if err := r.moveThread(tx, thread.ID, forum.Slug); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return r.GetThreadByID(thread.ID)
+}
+
+func (r *Repository) moveThread(tx *sqlx.Tx, threadID int, forumSlug string) error {
+	current := model.Thread{}
+	err := tx.Get(&current, `select id, forum, "isDeleted" from thread where id = $1 for update`, threadID)
+	if err != nil {
+		return Error(err)
+	}
+	if current.Forum == forumSlug {
+		return nil
+	}
+	if err := r.checkForumsWritable(tx, forumSlug); err != nil {
+		return err
+	}
+	posts, err := r.countThreadPosts(tx, threadID)
+	if err != nil {
+		return err
+	}
+	var authors []string
+	err = tx.Select(&authors,
+		`select author from thread where id = $1 union select author from post where thread = $1`,
+		threadID,
+	)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`update thread set forum = $1 where id = $2`, forumSlug, threadID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`update post set forum = $1 where thread = $2`, forumSlug, threadID); err != nil {
+		return err
+	}
+	if err := r.addForumUsers(tx, forumSlug, authors); err != nil {
+		return err
+	}
+	if err := r.removeInactiveForumUsers(tx, current.Forum, authors); err != nil {
+		return err
+	}
+	if current.IsDeleted {
+		return nil
+	}
+	if err := r.addForumCounts(tx, current.Forum, -1, -posts); err != nil {
+		return err
+	}
+	return r.addForumCounts(tx, forumSlug, 1, posts)
+}
+
+func (r *Repository) addThreadPosts(tx *sqlx.Tx, threadID, count int, lastPostAt time.Time, lastPostAuthor string) error {
+	_, err := tx.Exec(
+		`update thread set posts = posts + $1, last_post_at = $2, last_post_author = $3 where id = $4`,
+		count, lastPostAt, lastPostAuthor, threadID,
+	)
+	return err
+}
+
+// refreshThreadPosts recounts the summary fields after posts leave a thread.
+func (r *Repository) refreshThreadPosts(tx *sqlx.Tx, threadID int) error {
+	_, err := tx.Exec(
+		`update thread set (posts, last_post_at, last_post_author) = (
+			select count(*), max(created),
+				(select author from post where thread = $1 and not "isDeleted" order by created desc, id desc limit 1)
+			from post where thread = $1 and not "isDeleted"
+		) where id = $1`,
+		threadID,
+	)
+	return err
+}
+
+// GetThreadParticipants lists authors by post count, most active first, paging after
+// the count and nickname a cursor recorded. reverse walks back up from the cursor instead.
+func (r *Repository) GetThreadParticipants(threadID int, after *model.Cursor, limit int, reverse bool) ([]*model.Participant, error) {
+	params := []interface{}{threadID}
+	having := ""
+	if after != nil {
+		posts, err := after.KeyInt(0)
+		if err != nil {
+			return nil, err
+		}
+		params = append(params, posts, after.ID)
+		having = fmt.Sprintf("having (-count(*), author) %s (-$2::bigint, $3)", r.getSinceOperator(reverse))
+	}
+	query := fmt.Sprintf(
+		`select author, count(*) as posts from post where thread = $1 and not "isDeleted"
+		group by author %s order by posts %s, author %s %s`,
+		having, r.getOrder(!reverse), r.getOrder(reverse), r.getLimit(limit),
 	)
-	return thread, err
+	participants := make([]*model.Participant, 0)
+	err := r.db.Select(&participants, query, params...)
+	return participants, err
 }
@@ -7,10 +7,19 @@ import (
 	"strings"
 )
 
-func (r *Repository) GetThreadPosts(thread, limit int, since *int, sort string, desc bool) (model.Posts, error) {
+// GetThreadPosts pages after a cursor or after the post with the since id.
+func (r *Repository) GetThreadPosts(thread, limit int, since *int, after *model.Cursor, sort string, desc bool) (model.Posts, error) {
+	if sort == SortFlat || sort == "" {
+		return r.getThreadPostsFlat(thread, limit, since, after, desc)
+	}
+	if after != nil {
+		id, err := after.IntID()
+		if err != nil {
+			return nil, err
+		}
+		since = id
+	}
 	switch sort {
-	case SortFlat, "":
-		return r.getThreadPostsFlat(thread, limit, since, desc)
 	case SortTree:
 		return r.getThreadPostsTree(thread, limit, since, desc)
 	case SortParentTree:
@@ -19,7 +28,9 @@ func (r *Repository) GetThreadPosts(thread, limit int, since *int, sort string,
 	return nil, fmt.Errorf("%w: unknown sort method '%s'", consts.ErrNotFound, sort)
 }
 
-func (r *Repository) getThreadPostsFlat(thread, limit int, since *int, desc bool) (model.Posts, error) {
+// getThreadPostsFlat pages by creation time and id, which is how the list is ordered:
+// ids alone do not follow creation time once threads are merged.
+func (r *Repository) getThreadPostsFlat(thread, limit int, since *int, after *model.Cursor, desc bool) (model.Posts, error) {
 	order := "asc"
 	if desc {
 		order = "desc"
@@ -27,13 +38,25 @@ func (r *Repository) getThreadPostsFlat(thread, limit int, since *int, desc bool
 	orderBy := []string{"created " + order, "id " + order}
 	filter := "thread = $1"
 	params := []interface{}{thread}
-	if since != nil {
-		if desc {
-			filter += " and id < $2"
-		} else {
-			filter += " and id > $2"
+	switch {
+	case after != nil:
+		created, err := after.KeyTime(0)
+		if err != nil {
+			return nil, err
 		}
-		params = append(params, *since)
+		id, err := after.IntID()
+		if err != nil {
+			return nil, err
+		}
+		filter += fmt.Sprintf(" and (created, id) %s ($2::timestamptz, $3::bigint)", r.getSinceOperator(desc))
+		params = append(params, created, *id)
+	case since != nil:
+		sincePost, err := r.getPostFields("created, id", "id=$1", *since)
+		if err != nil {
+			return nil, err
+		}
+		filter += fmt.Sprintf(" and (created, id) %s ($2::timestamptz, $3::bigint)", r.getSinceOperator(desc))
+		params = append(params, sincePost.Created, sincePost.ID)
 	}
 	return r.getPosts(orderBy, limit, filter, params...)
 }
@@ -55,58 +78,76 @@ func (r *Repository) getThreadPostsTree(thread, limit int, since *int, desc bool
 }
 
 func (r *Repository) getThreadPostsParentTree(thread, limit int, since *int, desc bool) (model.Posts, error) {
-	conditions := []string{"parent=0", "thread=$1"}
-
+	conditions := []string{"thread = $1", "parent = 0"}
+	params := []interface{}{thread}
 	if since != nil {
-		var operator = ">"
-		if desc {
-			operator = "<"
-		}
 		sincePost, err := r.getPostFields("path", "id=$1", *since)
 		if err != nil {
 			return nil, err
 		}
-		sinceCond := fmt.Sprintf("path %s '%s'", operator, r.getRootPath(sincePost.Path))
-		conditions = append(conditions, sinceCond)
+		conditions = append(conditions, fmt.Sprintf("id %s $2", r.getSinceOperator(desc)))
+		params = append(params, sincePost.Path.Root())
 	}
-
-	filter := strings.Join(conditions, " and ")
-	var parents model.Posts
-	err := r.db.Select(&parents, fmt.Sprintf(
-		`select * from post where %s order by id %s limit %d`, filter, r.getOrder(desc), limit),
-		thread,
+	query := fmt.Sprintf(
+		`with roots as (
+			select id from post where %s order by id %s limit %d
+		)
+		select post.* from post
+			join roots on post.path[1] = roots.id
+			where post.thread = $1
+			order by post.path[1] %s, post.path`,
+		strings.Join(conditions, " and "), r.getOrder(desc), limit, r.getOrder(desc),
 	)
-	if err != nil {
-		return nil, err
-	}
 	posts := make(model.Posts, 0)
-	for _, parent := range parents {
-		var childs model.Posts
-		err := r.db.Select(&childs, fmt.Sprintf(
-			`select * from post where substring(path,1,7) = '%s' and parent<>0 order by path`, r.padPostID(parent.ID),
-		))
+	err := r.db.Select(&posts, query, params...)
+	r.hideDeletedPosts(posts...)
+	return posts, err
+}
+
+func (r *Repository) GetThreadPostsAround(post *model.Post, count int, sort string) (before, after model.Posts, err error) {
+	if sort == SortParentTree {
+		sort = SortTree
+	}
+	if before, err = r.GetThreadPosts(post.Thread, count, &post.ID, nil, sort, true); err != nil {
+		return
+	}
+	for i, j := 0, len(before)-1; i < j; i, j = i+1, j-1 {
+		before[i], before[j] = before[j], before[i]
+	}
+	after, err = r.GetThreadPosts(post.Thread, count, &post.ID, nil, sort, false)
+	return
+}
+
+// GetThreadPostPosition counts the posts listed before a post, comparing the same keys
+// each sort orders by: creation time and id for flat, path for tree and root id for parent_tree.
+func (r *Repository) GetThreadPostPosition(post *model.Post, sort string) (int, error) {
+	conditions := []string{"thread = $1"}
+	params := []interface{}{post.Thread}
+	switch sort {
+	case SortFlat, "":
+		conditions = append(conditions, "(created, id) < ($2::timestamptz, $3::bigint)")
+		params = append(params, post.Created, post.ID)
+	case SortTree:
+		sinceCond, err := r.getSinceCondition(&post.ID, true)
 		if err != nil {
-			return nil, err
+			return 0, err
 		}
-		posts = append(posts, parent)
-		posts = append(posts, childs...)
+		conditions = append(conditions, sinceCond)
+	case SortParentTree:
+		conditions = append(conditions, "parent = 0", "id < $2")
+		params = append(params, post.Path.Root())
+	default:
+		return 0, fmt.Errorf("%w: unknown sort method '%s'", consts.ErrNotFound, sort)
 	}
-	return posts, nil
+	var before int
+	err := r.db.Get(&before, "select count(*) from post where "+strings.Join(conditions, " and "), params...)
+	return before + 1, err
 }
 
 func (r *Repository) getSinceCondition(since *int, desc bool) (string, error) {
-	var operator = ">"
-	if desc {
-		operator = "<"
-	}
 	sincePost, err := r.getPostFields("path", "id=$1", *since)
 	if err != nil {
 		return "", err
 	}
-	return fmt.Sprintf("path %s '%s'", operator, sincePost.Path), nil
-}
-
-func (r *Repository) getRootPath(path string) string {
-	root := strings.Split(path, pathDelim)[0]
-	return root + strings.Repeat(pathDelim+zeroPathStud, maxTreeLevel-1)
+	return fmt.Sprintf("path %s '%s'", r.getSinceOperator(desc), sincePost.Path), nil
 }
@@ -0,0 +1,150 @@
+package repository
+
+import (
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"project/internal/consts"
+	"project/internal/model"
+)
+
+func (r *Repository) SplitThread(post *model.Post, thread model.ThreadCreate) (*model.Thread, error) {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	id, err := r.splitThread(tx, post, thread)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return r.GetThreadByID(id)
+}
+
+func (r *Repository) splitThread(tx *sqlx.Tx, post *model.Post, thread model.ThreadCreate) (int, error) {
+	source := model.Thread{}
+	err := tx.Get(&source, `select id, forum from thread where id = $1 and not "isDeleted" for update`, post.Thread)
+	if err != nil {
+		return 0, Error(err)
+	}
+	if err := r.checkForumsWritable(tx, source.Forum); err != nil {
+		return 0, err
+	}
+	root := model.Post{}
+	err = tx.Get(&root, `select id, thread, path from post where id = $1 for update`, post.ID)
+	if err != nil {
+		return 0, Error(err)
+	}
+	if root.Thread != source.ID {
+		return 0, fmt.Errorf("%w: post was moved to another thread", consts.ErrConflict)
+	}
+	var id int
+	err = tx.QueryRow(
+		`insert into thread (title, author, forum, message, slug, created) values ($1, $2, $3, $4, $5, $6) returning id`,
+		thread.Title, thread.Author, source.Forum, thread.Message, thread.Slug, thread.Created,
+	).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	// Paths are cut as in model.PostPath.SplitFrom.
+	_, err = tx.Exec(
+		`update post set thread = $1,
+			path = path[$2::int:],
+			parent = case when id = $3 then 0 else parent end
+		where thread = $4 and path >= $5 and path < $6`,
+		id, len(root.Path), root.ID, source.ID, root.Path, root.Path.UpperBound(),
+	)
+	if err != nil {
+		return 0, err
+	}
+	if err := r.refreshThreadPosts(tx, source.ID); err != nil {
+		return 0, err
+	}
+	return id, r.refreshThreadPosts(tx, id)
+}
+
+func (r *Repository) MergeThreads(source, target *model.Thread) (*model.Thread, error) {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	if err := r.mergeThreads(tx, source, target); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return r.GetThreadByID(target.ID)
+}
+
+// mergeThreads moves the source posts into the target thread as they are: source roots
+// become target roots, and since post ids are unique their paths need no rewrite.
+func (r *Repository) mergeThreads(tx *sqlx.Tx, source, target *model.Thread) error {
+	var locked []int
+	err := tx.Select(&locked,
+		`select id from thread where id in ($1, $2) and not "isDeleted" order by id for update`,
+		source.ID, target.ID,
+	)
+	if err != nil {
+		return err
+	}
+	if len(locked) != 2 {
+		return consts.ErrNotFound
+	}
+	if err := r.checkForumsWritable(tx, source.Forum, target.Forum); err != nil {
+		return err
+	}
+	posts, err := r.countThreadPosts(tx, source.ID)
+	if err != nil {
+		return err
+	}
+	var postAuthors []string
+	if err = tx.Select(&postAuthors, `select distinct author from post where thread = $1`, source.ID); err != nil {
+		return err
+	}
+	authors := append([]string{source.Author}, postAuthors...)
+	_, err = tx.Exec(`update post set thread = $1, forum = $2 where thread = $3`, target.ID, target.Forum, source.ID)
+	if err != nil {
+		return err
+	}
+	if err := r.mergeThreadVotes(tx, source.ID, target.ID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`delete from thread where id = $1`, source.ID); err != nil {
+		return err
+	}
+	if err := r.refreshThreadPosts(tx, target.ID); err != nil {
+		return err
+	}
+	if err := r.addForumUsers(tx, target.Forum, postAuthors); err != nil {
+		return err
+	}
+	if err := r.removeInactiveForumUsers(tx, source.Forum, authors); err != nil {
+		return err
+	}
+	if err := r.addForumCounts(tx, source.Forum, -1, -posts); err != nil {
+		return err
+	}
+	return r.addForumCounts(tx, target.Forum, 0, posts)
+}
+
+func (r *Repository) mergeThreadVotes(tx *sqlx.Tx, source, target int) error {
+	_, err := tx.Exec(
+		`delete from vote where thread = $1 and nickname in (select nickname from vote where thread = $2)`,
+		source, target,
+	)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`update vote set thread = $1 where thread = $2`, target, source); err != nil {
+		return err
+	}
+	_, err = tx.Exec(
+		`update thread set votes = (select coalesce(sum(voice), 0) from vote where thread = $1) where id = $1`,
+		target,
+	)
+	return err
+}
@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"errors"
+	"project/internal/consts"
+	"project/internal/model"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSplitThreadRewritesPaths(t *testing.T) {
+	r := newTestRepository(t)
+	forum := newTestForum(t, r)
+	source := createTestThread(t, r, forum, "source")
+	a := createTestPost(t, r, source, 0)
+	b := createTestPost(t, r, source, a.ID)
+	c := createTestPost(t, r, source, b.ID)
+	d := createTestPost(t, r, source, 0)
+
+	split, err := r.SplitThread(b, model.ThreadCreate{
+		Author:  "author",
+		Created: time.Now().Format(time.RFC3339),
+		Message: "split",
+		Title:   "split",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantSource := map[int]model.PostPath{a.ID: {a.ID}, d.ID: {d.ID}}
+	if got := getTestPaths(t, r, source.ID); !reflect.DeepEqual(got, wantSource) {
+		t.Errorf("source paths = %v, want %v", got, wantSource)
+	}
+	wantSplit := map[int]model.PostPath{b.ID: {b.ID}, c.ID: {b.ID, c.ID}}
+	if got := getTestPaths(t, r, split.ID); !reflect.DeepEqual(got, wantSplit) {
+		t.Errorf("split paths = %v, want %v", got, wantSplit)
+	}
+	moved, err := r.GetPostByID(b.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if moved.Parent != 0 {
+		t.Errorf("split root parent = %d, want 0", moved.Parent)
+	}
+	if split.Posts != 2 {
+		t.Errorf("split thread posts = %d, want 2", split.Posts)
+	}
+}
+
+func TestMergeThreadsKeepsPaths(t *testing.T) {
+	r := newTestRepository(t)
+	forum := newTestForum(t, r)
+	source := createTestThread(t, r, forum, "source")
+	target := createTestThread(t, r, forum, "target")
+	a := createTestPost(t, r, source, 0)
+	b := createTestPost(t, r, source, a.ID)
+	c := createTestPost(t, r, target, 0)
+
+	merged, err := r.MergeThreads(source, target)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[int]model.PostPath{a.ID: {a.ID}, b.ID: {a.ID, b.ID}, c.ID: {c.ID}}
+	if got := getTestPaths(t, r, target.ID); !reflect.DeepEqual(got, want) {
+		t.Errorf("merged paths = %v, want %v", got, want)
+	}
+	if merged.Posts != 3 {
+		t.Errorf("merged thread posts = %d, want 3", merged.Posts)
+	}
+	updated, err := r.GetForumBySlug(forum.Slug)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Posts != 3 || updated.Threads != 1 {
+		t.Errorf("forum counts = %d posts, %d threads, want 3 posts, 1 thread", updated.Posts, updated.Threads)
+	}
+}
+
+func TestSplitAndMergeRefuseArchivedForums(t *testing.T) {
+	r := newTestRepository(t)
+	forum := newTestForum(t, r)
+	archived, err := r.CreateForum("Archived", "archived", "author")
+	if err != nil {
+		t.Fatal(err)
+	}
+	source := createTestThread(t, r, forum, "source")
+	target := createTestThread(t, r, archived, "target")
+	post := createTestPost(t, r, target, 0)
+	isArchived := true
+	if _, err := r.UpdateForum(archived.Slug, model.ForumUpdate{IsArchived: &isArchived}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := r.MergeThreads(source, target); !errors.Is(err, consts.ErrForbidden) {
+		t.Errorf("merge into archived forum error = %v, want %v", err, consts.ErrForbidden)
+	}
+	_, err = r.SplitThread(post, model.ThreadCreate{Author: "author", Created: time.Now().Format(time.RFC3339), Title: "split"})
+	if !errors.Is(err, consts.ErrForbidden) {
+		t.Errorf("split in archived forum error = %v, want %v", err, consts.ErrForbidden)
+	}
+	if _, err := r.GetThreadByID(source.ID); err != nil {
+		t.Errorf("source thread after refused merge: %v", err)
+	}
+}
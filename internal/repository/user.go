@@ -3,13 +3,25 @@ package repository
 import (
 	"database/sql"
 	"fmt"
+	"github.com/jmoiron/sqlx"
 	"project/internal/consts"
 	"project/internal/model"
 )
 
 func (r *Repository) GetUserByNickname(nickname string) (*model.User, error) {
+	return r.getUserByNickname(r.db, nickname)
+}
+
+func (r *Repository) getUserByNickname(db sqlx.Queryer, nickname string) (*model.User, error) {
 	user := model.User{}
-	err := r.db.Get(&user, `select * from "user" where nickname = $1`, nickname)
+	err := sqlx.Get(db, &user, `select * from "user" where nickname = $1`, nickname)
+	if err == sql.ErrNoRows {
+		err = sqlx.Get(db, &user,
+			`select "user".* from "user" join user_alias on user_alias.current = "user".nickname
+			where user_alias.nickname = $1`,
+			nickname,
+		)
+	}
 	if err != nil {
 		return nil, Error(err)
 	}
@@ -23,6 +35,9 @@ func (r *Repository) GetUserNickname(nickname string) (string, error) {
 	}
 	user := model.User{}
 	err = r.db.Get(&user, `select id,nickname from "user" where nickname = $1`, nickname)
+	if err == sql.ErrNoRows {
+		return r.getAliasedUserNickname(nickname)
+	}
 	if err != nil {
 		return "", Error(err)
 	}
@@ -30,6 +45,15 @@ func (r *Repository) GetUserNickname(nickname string) (string, error) {
 	return user.Nickname, nil
 }
 
+func (r *Repository) getAliasedUserNickname(alias string) (string, error) {
+	var nickname string
+	err := r.db.Get(&nickname, `select current from user_alias where nickname = $1`, alias)
+	if err != nil {
+		return "", Error(err)
+	}
+	return nickname, nil
+}
+
 func (r *Repository) getUserByID(userID int) (*model.User, error) {
 	user := model.User{}
 	err := r.db.Get(&user, `select * from "user" where id = $1`, userID)
@@ -51,7 +75,8 @@ func (r *Repository) getUserByEmail(email string) (*model.User, error) {
 func (r *Repository) GetUsersByNicknameOrEmail(nickname, email string) ([]*model.User, error) {
 	var users []*model.User
 	err := r.db.Select(&users,
-		`select * from "user" where nickname = $1 or email = $2`,
+		`select * from "user" where nickname = $1 or email = $2
+			or nickname = (select current from user_alias where nickname = $1)`,
 		nickname, email,
 	)
 	if err != nil {
@@ -93,3 +118,111 @@ func (r *Repository) UpdateUserByNickname(nickname, email, fullname, about strin
 	}
 	return nil
 }
+
+func (r *Repository) RenameUser(user *model.User, nickname string) (*model.User, error) {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	if err := r.renameUser(tx, user.Nickname, nickname); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	r.users.Remove(user.ID, user.Nickname)
+	r.users.Add(user.ID, nickname)
+	return r.getUserByID(user.ID)
+}
+
+func (r *Repository) renameUser(tx *sqlx.Tx, oldNick, newNick string) error {
+	result, err := tx.Exec(`update "user" set nickname = $1 where nickname = $2`, newNick, oldNick)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return consts.ErrNotFound
+	}
+	queries := []string{
+		`update forum set "user" = $1 where "user" = $2`,
+		`update thread set author = $1 where author = $2`,
+		`update thread set last_post_author = $1 where last_post_author = $2`,
+		`update post set author = $1 where author = $2`,
+		`update post_revision set editor = $1 where editor = $2`,
+		`update vote set nickname = $1 where nickname = $2`,
+		`insert into forum_user (forum, "user")
+			select forum, $1 from forum_user where "user" = $2
+			on conflict do nothing`,
+		`delete from forum_user where "user" = $2 and "user" <> $1`,
+		`update user_alias set current = $1 where current = $2`,
+		`insert into user_alias (nickname, current) values ($2, $1)
+			on conflict (nickname) do update set current = excluded.current`,
+	}
+	if _, err := tx.Exec(`delete from user_alias where nickname = $1`, newNick); err != nil {
+		return err
+	}
+	for _, query := range queries {
+		if _, err := tx.Exec(query, newNick, oldNick); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Repository) DeleteUser(user *model.User) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return err
+	}
+	if err := r.deleteUser(tx, user.Nickname); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	r.users.Remove(user.ID, user.Nickname)
+	return nil
+}
+
+func (r *Repository) deleteUser(tx *sqlx.Tx, nickname string) error {
+	_, err := tx.Exec(
+		`update thread set votes = thread.votes - vote.voice from vote
+		where vote.thread = thread.id and vote.nickname = $1`,
+		nickname,
+	)
+	if err != nil {
+		return err
+	}
+	queries := []string{
+		`delete from vote where nickname = $1`,
+		`delete from forum_user where "user" = $1`,
+		`delete from user_alias where current = $1`,
+	}
+	for _, query := range queries {
+		if _, err := tx.Exec(query, nickname); err != nil {
+			return err
+		}
+	}
+	anonymize := []string{
+		`update thread set author = $2 where author = $1`,
+		`update thread set last_post_author = $2 where last_post_author = $1`,
+		`update post set author = $2 where author = $1`,
+		`update post_revision set editor = $2 where editor = $1`,
+		`update forum set "user" = $2 where "user" = $1`,
+	}
+	for _, query := range anonymize {
+		if _, err := tx.Exec(query, nickname, consts.DeletedUserNickname); err != nil {
+			return err
+		}
+	}
+	result, err := tx.Exec(`delete from "user" where nickname = $1`, nickname)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return consts.ErrNotFound
+	}
+	return nil
+}
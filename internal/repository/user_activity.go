@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"fmt"
+	"project/internal/model"
+	"strings"
+)
+
+// GetUserThreads pages either by a creation time, inclusive, or after a cursor.
+func (r *Repository) GetUserThreads(nickname, since string, after *model.Cursor, limit int, desc bool) (model.Threads, error) {
+	conditions := []string{"author::citext = $1::citext", threadVisible}
+	params := []interface{}{nickname}
+	if since != "" {
+		params = append(params, since)
+		conditions = append(conditions, fmt.Sprintf("created %s= $%d", r.getSinceOperator(desc), len(params)))
+	}
+	if after != nil {
+		keyset, keyParams, err := r.getThreadKeyset("created", r.getSinceOperator(desc), *after, len(params))
+		if err != nil {
+			return nil, err
+		}
+		params = append(params, keyParams...)
+		conditions = append(conditions, keyset)
+	}
+	query := fmt.Sprintf(
+		"select * from thread where %s order by created %s, id %s %s",
+		strings.Join(conditions, " and "), r.getOrder(desc), r.getOrder(desc), r.getLimit(limit),
+	)
+	threads := make(model.Threads, 0)
+	err := r.db.Select(&threads, query, params...)
+	return threads, err
+}
+
+func (r *Repository) GetUserPosts(nickname, since string, after *model.Cursor, limit int, desc bool) (model.Posts, error) {
+	conditions := []string{"author::citext = $1::citext", `not "isDeleted"`, postThreadVisible}
+	params := []interface{}{nickname}
+	if since != "" {
+		params = append(params, since)
+		conditions = append(conditions, fmt.Sprintf("created %s= $%d", r.getSinceOperator(desc), len(params)))
+	}
+	if after != nil {
+		created, err := after.KeyTime(0)
+		if err != nil {
+			return nil, err
+		}
+		id, err := after.IntID()
+		if err != nil {
+			return nil, err
+		}
+		params = append(params, created, *id)
+		conditions = append(conditions, fmt.Sprintf(
+			"(created, id) %s ($%d::timestamptz, $%d::bigint)", r.getSinceOperator(desc), len(params)-1, len(params),
+		))
+	}
+	orderBy := []string{"created " + r.getOrder(desc), "id " + r.getOrder(desc)}
+	return r.getPosts(orderBy, limit, strings.Join(conditions, " and "), params...)
+}
+
+func (r *Repository) GetUserVotes(nickname string, since *int, limit int, desc bool) ([]*model.VoteDB, error) {
+	conditions := []string{"nickname::citext = $1::citext"}
+	params := []interface{}{nickname}
+	if since != nil {
+		conditions = append(conditions, fmt.Sprintf("id %s $2", r.getSinceOperator(desc)))
+		params = append(params, *since)
+	}
+	query := fmt.Sprintf(
+		"select * from vote where %s order by id %s %s",
+		strings.Join(conditions, " and "), r.getOrder(desc), r.getLimit(limit),
+	)
+	votes := make([]*model.VoteDB, 0)
+	err := r.db.Select(&votes, query, params...)
+	return votes, err
+}
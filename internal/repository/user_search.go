@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"fmt"
+	"project/internal/model"
+	"strings"
+	"unicode"
+)
+
+// maxRune closes the nickname prefix range: nicknames are compared lowercased
+// in byte order, so every nickname starting with a prefix sorts before prefix+maxRune.
+const maxRune = "\U0010FFFF"
+
+func (r *Repository) SearchUsers(q, since string, limit int, desc bool) (model.Users, error) {
+	users := make(model.Users, 0)
+	prefix := strings.ToLower(strings.TrimSpace(q))
+	if prefix == "" {
+		return users, nil
+	}
+	params := []interface{}{prefix, prefix + maxRune}
+	nickMatch := "nickname >= $1 and nickname < $2"
+	nameMatch := "false"
+	nameRank := "0"
+	if words := r.getSearchWords(q); words != "" {
+		params = append(params, words)
+		nameMatch = "to_tsvector('simple', fullname) @@ to_tsquery('simple', $3)"
+		nameRank = "ts_rank(to_tsvector('simple', fullname), to_tsquery('simple', $3))"
+	}
+	sinceFilter := ""
+	if since != "" {
+		params = append(params, since)
+		sinceFilter = fmt.Sprintf(
+			"where (-rank, nickname) %s (select -rank, nickname from found where nickname = $%d)",
+			r.getSinceOperator(desc), len(params),
+		)
+	}
+	query := fmt.Sprintf(
+		`with found as (
+			select "user".*,
+				(case when nickname = $1 then 2 when %s then 1 else 0 end) + %s as rank
+			from "user" where (%s) or %s
+		)
+		select * from found %s order by -rank %s, nickname %s %s`,
+		nickMatch, nameRank, nickMatch, nameMatch,
+		sinceFilter, r.getOrder(desc), r.getOrder(desc), r.getLimit(limit),
+	)
+	var found []struct {
+		model.User
+		Rank float64 `db:"rank"`
+	}
+	if err := r.db.Select(&found, query, params...); err != nil {
+		return nil, err
+	}
+	for i := range found {
+		users = append(users, &found[i].User)
+	}
+	return users, nil
+}
+
+// getSearchWords turns free text into a prefix tsquery, dropping anything but letters and digits.
+func (r *Repository) getSearchWords(q string) string {
+	words := strings.FieldsFunc(q, func(c rune) bool {
+		return !unicode.IsLetter(c) && !unicode.IsDigit(c)
+	})
+	for i, word := range words {
+		words[i] = word + ":*"
+	}
+	return strings.Join(words, " & ")
+}
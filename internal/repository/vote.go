@@ -2,42 +2,51 @@ package repository
 
 import (
 	"database/sql"
+	"github.com/jmoiron/sqlx"
 	"project/internal/model"
 )
 
 func (r *Repository) AddThreadVote(thread *model.Thread, nickname string, voice int) (newVotes int, err error) {
-	oldVoice, err := r.getVoice(nickname, thread.ID)
-	if err != nil {
-		return
-	}
-	if oldVoice == voice {
-		return thread.Votes, nil
-	}
-	newVoice := voice - oldVoice
 	tx, err := r.db.Beginx()
 	if err != nil {
 		return
 	}
-	err = tx.Get(&newVotes, `update thread set votes = votes + $1 where id = $2 returning votes`, newVoice, thread.ID)
+	newVotes, err = r.addThreadVote(tx, thread, nickname, voice)
 	if err != nil {
 		tx.Rollback()
 		return
 	}
-	if _, err = tx.Exec(`delete from vote where thread = $1 and nickname = $2`, thread.ID, nickname); err != nil {
-		tx.Rollback()
-		return
-	}
-	if _, err = tx.Exec(`insert into vote (thread, nickname, voice) values ($1, $2, $3)`, thread.ID, nickname, voice); err != nil {
-		tx.Rollback()
-		return
-	}
 	err = tx.Commit()
 	return
 }
 
-func (r *Repository) getVoice(nickname string, threadID int) (int, error) {
+func (r *Repository) addThreadVote(tx *sqlx.Tx, thread *model.Thread, nickname string, voice int) (int, error) {
+	if err := r.checkThreadWritable(tx, thread.ID, false); err != nil {
+		return 0, err
+	}
+	oldVoice, err := r.getVoice(tx, nickname, thread.ID)
+	if err != nil {
+		return 0, err
+	}
+	var newVotes int
+	if oldVoice == voice {
+		err = tx.Get(&newVotes, `select votes from thread where id = $1`, thread.ID)
+		return newVotes, err
+	}
+	err = tx.Get(&newVotes, `update thread set votes = votes + $1 where id = $2 returning votes`, voice-oldVoice, thread.ID)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(`delete from vote where thread = $1 and nickname = $2`, thread.ID, nickname); err != nil {
+		return 0, err
+	}
+	_, err = tx.Exec(`insert into vote (thread, nickname, voice) values ($1, $2, $3)`, thread.ID, nickname, voice)
+	return newVotes, err
+}
+
+func (r *Repository) getVoice(tx *sqlx.Tx, nickname string, threadID int) (int, error) {
 	var voice int
-	err := r.db.Get(&voice, `select voice from vote where nickname = $1 and thread = $2`, nickname, threadID)
+	err := tx.Get(&voice, `select voice from vote where nickname = $1 and thread = $2`, nickname, threadID)
 	if err == sql.ErrNoRows {
 		return 0, nil
 	}
@@ -3,8 +3,11 @@ package internal
 import (
 	"fmt"
 	"project/internal/consts"
+	"project/internal/diff"
 	"project/internal/model"
 	"project/internal/repository"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -21,6 +24,9 @@ func (u *Usecase) getUserByNickname(nickname string) (*model.User, error) {
 }
 
 func (u *Usecase) createUser(nickname, email, fullname, about string) ([]*model.User, error) {
+	if err := u.checkNicknameAllowed(nickname); err != nil {
+		return nil, err
+	}
 	existing, err := u.repo.GetUsersByNicknameOrEmail(nickname, email)
 	if err != nil && err != consts.ErrNotFound {
 		return nil, err
@@ -46,10 +52,135 @@ func (u *Usecase) updateUser(nickname, email, fullname, about string) (*model.Us
 	if about == "" {
 		about = userToUpdate.About
 	}
-	if err := u.repo.UpdateUserByNickname(nickname, email, fullname, about); err != nil {
+	if err := u.repo.UpdateUserByNickname(userToUpdate.Nickname, email, fullname, about); err != nil {
 		return nil, err
 	}
-	return u.repo.GetUserByNickname(nickname)
+	return u.repo.GetUserByNickname(userToUpdate.Nickname)
+}
+
+// checkNicknameAllowed keeps users from taking the name that marks anonymized content.
+func (u *Usecase) checkNicknameAllowed(nickname string) error {
+	if strings.EqualFold(nickname, consts.DeletedUserNickname) {
+		return fmt.Errorf("%w: nickname '%s' is reserved", consts.ErrBadRequest, consts.DeletedUserNickname)
+	}
+	return nil
+}
+
+func (u *Usecase) renameUser(nickname, newNickname string) (*model.User, error) {
+	user, err := u.repo.GetUserByNickname(nickname)
+	if err != nil {
+		return nil, err
+	}
+	if newNickname == "" {
+		return nil, fmt.Errorf("%w: nickname can not be empty", consts.ErrConflict)
+	}
+	if err := u.checkNicknameAllowed(newNickname); err != nil {
+		return nil, err
+	}
+	existing, err := u.repo.GetUsersByNicknameOrEmail(newNickname, "")
+	if err != nil {
+		return nil, err
+	}
+	for _, other := range existing {
+		if other.ID != user.ID {
+			return nil, fmt.Errorf("%w: user with this nickname already exists", consts.ErrConflict)
+		}
+	}
+	return u.repo.RenameUser(user, newNickname)
+}
+
+func (u *Usecase) deleteUser(nickname string) error {
+	user, err := u.repo.GetUserByNickname(nickname)
+	if err != nil {
+		return err
+	}
+	return u.repo.DeleteUser(user)
+}
+
+func (u *Usecase) searchUsers(q, since, cursor string, limit int, desc bool) (model.Users, *model.Page, error) {
+	at, err := u.getPagePosition(cursor, "", since)
+	if err != nil {
+		return nil, nil, err
+	}
+	users, err := u.repo.SearchUsers(q, at.ID, limit, desc != at.Before)
+	if err != nil {
+		return nil, nil, err
+	}
+	if at.Before {
+		reverseList(users)
+	}
+	return users, u.getUsersPage(users, at, at.ID != "", limit), nil
+}
+
+func (u *Usecase) getUserThreads(nickname, since, cursor string, limit int, desc bool) (model.Threads, *model.Page, error) {
+	user, err := u.repo.GetUserByNickname(nickname)
+	if err != nil {
+		return nil, nil, err
+	}
+	at, after, err := u.getPageCursor(cursor, "")
+	if err != nil {
+		return nil, nil, err
+	}
+	if after != nil {
+		since = ""
+	}
+	threads, err := u.repo.GetUserThreads(user.Nickname, since, after, limit, desc != at.Before)
+	if err != nil {
+		return nil, nil, err
+	}
+	if at.Before {
+		reverseList(threads)
+	}
+	return threads, u.getThreadsPage(threads, at, after != nil || since != "", limit), nil
+}
+
+func (u *Usecase) getUserPosts(nickname, since, cursor string, limit int, desc bool) (model.Posts, *model.Page, error) {
+	user, err := u.repo.GetUserByNickname(nickname)
+	if err != nil {
+		return nil, nil, err
+	}
+	at, after, err := u.getPageCursor(cursor, "")
+	if err != nil {
+		return nil, nil, err
+	}
+	if after != nil {
+		since = ""
+	}
+	posts, err := u.repo.GetUserPosts(user.Nickname, since, after, limit, desc != at.Before)
+	if err != nil {
+		return nil, nil, err
+	}
+	if at.Before {
+		reverseList(posts)
+	}
+	return posts, u.getPostsPage(posts, at, after != nil || since != "", limit), nil
+}
+
+func (u *Usecase) getUserVotes(nickname string, since *int, cursor string, limit int, desc bool) ([]*model.VoteDB, *model.Page, error) {
+	user, err := u.repo.GetUserByNickname(nickname)
+	if err != nil {
+		return nil, nil, err
+	}
+	at, since, err := u.getPageIntPosition(cursor, "", since)
+	if err != nil {
+		return nil, nil, err
+	}
+	votes, err := u.repo.GetUserVotes(user.Nickname, since, limit, desc != at.Before)
+	if err != nil {
+		return nil, nil, err
+	}
+	if at.Before {
+		reverseList(votes)
+	}
+	rows := make([]model.Cursor, 0, len(votes))
+	for _, vote := range votes {
+		rows = append(rows, model.Cursor{ID: strconv.Itoa(vote.ID)})
+	}
+	return votes, model.NewPage(at, since != nil, rows, limit), nil
+}
+
+func (u *Usecase) exportUser(nickname string, write func(model.ExportRecord) error) error {
+	return u.repo.ExportUser(nickname, write)
 }
 
 func (u *Usecase) createForum(title, slug, nickname string) (*model.Forum, error) {
@@ -69,10 +200,27 @@ func (u *Usecase) createForum(title, slug, nickname string) (*model.Forum, error
 	return u.repo.CreateForum(title, slug, userNick)
 }
 
+func (u *Usecase) updateForum(slug string, forum model.ForumUpdate) (*model.Forum, error) {
+	if forum.User != "" {
+		owner, err := u.repo.GetUserNickname(forum.User)
+		if err != nil {
+			return nil, err
+		}
+		forum.User = owner
+	}
+	return u.repo.UpdateForum(slug, forum)
+}
+
+func (u *Usecase) deleteForum(slug string) error {
+	return u.repo.DeleteForum(slug)
+}
+
 func (u *Usecase) createThread(forumSlug string, thread model.ThreadCreate) (*model.Thread, error) {
-	if _, err := u.repo.GetUserNickname(thread.Author); err != nil {
+	author, err := u.repo.GetUserNickname(thread.Author)
+	if err != nil {
 		return nil, err
 	}
+	thread.Author = author
 	forum, err := u.repo.GetForumSlug(forumSlug)
 	if err != nil {
 		return nil, err
@@ -99,68 +247,247 @@ func (u *Usecase) updateThread(threadSlugOrID string, message, title string) (*m
 	return u.repo.UpdateThread(threadSlugOrID, message, title)
 }
 
-func (u *Usecase) createPosts(threadSlugOrID string, posts []*model.PostCreate) (model.Posts, error) {
+func (u *Usecase) moderateThread(threadSlugOrID string, state model.ThreadModerate) (*model.Thread, error) {
+	return u.repo.ModerateThread(threadSlugOrID, state)
+}
+
+func (u *Usecase) moveThread(threadSlugOrID, forumSlug string) (*model.Thread, error) {
 	thread, err := u.repo.GetThreadFieldsBySlugOrID("id, forum", threadSlugOrID)
 	if err != nil {
 		return nil, err
 	}
-	if err := u.checkPostsCreate(posts, thread.ID); err != nil {
+	forum, err := u.repo.GetForumSlug(forumSlug)
+	if err != nil {
 		return nil, err
 	}
-	return u.repo.CreatePosts(posts, thread)
+	return u.repo.MoveThread(thread, forum)
 }
 
-func (u *Usecase) checkPostsCreate(posts []*model.PostCreate, threadID int) error {
-	for _, post := range posts {
-		if err := u.checkPostCreate(post, threadID); err != nil {
-			return err
+func (u *Usecase) splitThread(postID int, thread model.ThreadCreate) (*model.Thread, error) {
+	post, err := u.repo.GetPostByID(postID)
+	if err != nil {
+		return nil, err
+	}
+	if post.IsDeleted {
+		return nil, fmt.Errorf("%w: cannot split thread at a deleted post", consts.ErrConflict)
+	}
+	if thread.Author == "" {
+		thread.Author = post.Author
+	} else if thread.Author, err = u.repo.GetUserNickname(thread.Author); err != nil {
+		return nil, err
+	}
+	if thread.Message == "" {
+		thread.Message = post.Message
+	}
+	if thread.Slug != "" {
+		existing, err := u.repo.GetThreadBySlug(thread.Slug)
+		if err != nil && err != consts.ErrNotFound {
+			return nil, err
+		}
+		if existing != nil {
+			return existing, fmt.Errorf("%w: thread with this slug already exists", consts.ErrConflict)
 		}
 	}
-	return nil
+	if thread.Created == "" {
+		thread.Created = time.Now().Format(time.RFC3339)
+	}
+	return u.repo.SplitThread(post, thread)
 }
 
-func (u *Usecase) checkPostCreate(post *model.PostCreate, threadID int) error {
-	if _, err := u.repo.GetUserNickname(post.Author); err != nil {
-		return err
+func (u *Usecase) mergeThreads(sourceSlugOrID, targetSlugOrID string) (*model.Thread, error) {
+	source, err := u.repo.GetThreadBySlugOrID(sourceSlugOrID)
+	if err != nil {
+		return nil, err
 	}
-	if post.Parent != 0 {
-		parent, err := u.repo.GetPostByID(post.Parent)
-		if err == consts.ErrNotFound {
-			return fmt.Errorf("%w: post parent do not exists", consts.ErrConflict)
-		}
-		if err != nil {
-			return err
-		}
-		if parent.Thread != threadID {
-			return fmt.Errorf("%w: parent post was created in another thread", consts.ErrConflict)
-		}
+	target, err := u.repo.GetThreadBySlugOrID(targetSlugOrID)
+	if err != nil {
+		return nil, err
 	}
-	return nil
+	if source.ID == target.ID {
+		return nil, fmt.Errorf("%w: cannot merge thread into itself", consts.ErrConflict)
+	}
+	return u.repo.MergeThreads(source, target)
+}
+
+func (u *Usecase) deleteThread(threadSlugOrID string) (*model.Thread, error) {
+	return u.repo.DeleteThread(threadSlugOrID)
+}
+
+func (u *Usecase) restoreThread(threadSlugOrID string) (*model.Thread, error) {
+	return u.repo.RestoreThread(threadSlugOrID)
+}
+
+func (u *Usecase) purgeThread(threadSlugOrID string) error {
+	return u.repo.PurgeThread(threadSlugOrID)
+}
+
+func (u *Usecase) createPosts(threadSlugOrID string, posts []*model.PostCreate) (model.Posts, error) {
+	thread, err := u.repo.GetThreadFieldsBySlugOrID("id, forum", threadSlugOrID)
+	if err != nil {
+		return nil, err
+	}
+	return u.repo.CreatePosts(posts, thread)
 }
 
 func (u *Usecase) getForum(slug string) (*model.Forum, error) {
 	return u.repo.GetForumBySlug(slug)
 }
 
-func (u *Usecase) getForumThreads(forumSlug, since string, limit int, desc bool) (model.Threads, error) {
+func (u *Usecase) getForums(owner, sort, since, cursor string, limit int, desc bool) (model.Forums, *model.Page, error) {
+	if owner != "" {
+		ownerNick, err := u.repo.GetUserNickname(owner)
+		if err != nil {
+			return nil, nil, err
+		}
+		owner = ownerNick
+	}
+	at, after, err := u.getPageCursor(cursor, sort)
+	if err != nil {
+		return nil, nil, err
+	}
+	if after != nil {
+		since = ""
+	}
+	forums, err := u.repo.GetForums(owner, sort, since, after, limit, desc != at.Before)
+	if err != nil {
+		return nil, nil, err
+	}
+	if at.Before {
+		reverseList(forums)
+	}
+	rows := make([]model.Cursor, 0, len(forums))
+	for _, forum := range forums {
+		rows = append(rows, model.Cursor{Key: []string{u.getForumSortKey(forum, sort)}, ID: forum.Slug})
+	}
+	return forums, model.NewPage(at, after != nil || since != "", rows, limit), nil
+}
+
+// getForumSortKey is the value of the column a forum list is ordered by.
+func (u *Usecase) getForumSortKey(forum *model.Forum, sort string) string {
+	switch sort {
+	case repository.ForumSortTitle:
+		return forum.Title
+	case repository.ForumSortPosts:
+		return strconv.Itoa(forum.Posts)
+	case repository.ForumSortThreads:
+		return strconv.Itoa(forum.Threads)
+	default:
+		return forum.Created
+	}
+}
+
+// getForumThreads lists a forum's pinned threads, then the others in the requested order.
+// The legacy since parameter only pages the others.
+func (u *Usecase) getForumThreads(forumSlug, since, cursor, sort, window string, archived bool, limit int, desc bool) (model.Threads, *model.Page, error) {
 	forum, err := u.repo.GetForumSlug(forumSlug)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	var threads model.Threads
-	if since == "" {
-		threads, err = u.repo.GetForumThreads(forum.Slug, limit, desc)
-	} else {
-		threads, err = u.repo.GetForumThreadsSince(forum.Slug, since, limit, desc)
+	ranked := sort != "" && sort != repository.ThreadSortCreated
+	if since != "" {
+		return u.getForumThreadsSince(forum.Slug, since, sort, window, ranked, archived, limit, desc)
 	}
+	at, after, err := u.getPageCursor(cursor, sort)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	pinned := func(after *model.Cursor, limit int, reverse bool) (model.Threads, error) {
+		return u.repo.GetForumPinnedThreads(forum.Slug, archived, after, limit, reverse)
+	}
+	regular := func(after *model.Cursor, limit int, reverse bool) (model.Threads, error) {
+		switch {
+		case ranked:
+			return u.repo.GetForumThreadsRanked(forum.Slug, sort, window, nil, after, archived, limit, reverse)
+		case after != nil:
+			return u.repo.GetForumThreadsAfter(forum.Slug, *after, archived, limit, desc != reverse)
+		default:
+			return u.repo.GetForumThreads(forum.Slug, archived, limit, desc)
+		}
+	}
+	threads, err := u.pageForumThreads(pinned, regular, at, after, limit)
+	if err != nil {
+		return nil, nil, err
+	}
+	return threads, u.getThreadsPage(threads, at, after != nil, limit), nil
+}
+
+// threadLister fetches up to limit threads of one part of a forum's thread list after a
+// position in it, or from its start without one. reverse walks backwards, nearest first.
+type threadLister func(after *model.Cursor, limit int, reverse bool) (model.Threads, error)
+
+// pageForumThreads pages the pinned and the regular threads of a forum as one list:
+// a page that runs out of pinned threads continues with the first regular ones,
+// and a before page that runs out of regular threads continues with the last pinned ones.
+func (u *Usecase) pageForumThreads(pinned, regular threadLister, at model.Cursor, after *model.Cursor, limit int) (model.Threads, error) {
+	fits := func(threads model.Threads) bool {
+		return limit == 0 || len(threads) < limit
+	}
+	remaining := func(threads model.Threads) int {
+		if limit == 0 {
+			return 0
+		}
+		return limit - len(threads)
+	}
+	switch {
+	case after == nil || (after.Pinned && !at.Before):
+		threads, err := pinned(after, limit, false)
+		if err != nil || !fits(threads) {
+			return threads, err
+		}
+		rest, err := regular(nil, remaining(threads), false)
+		return append(threads, rest...), err
+	case after.Pinned:
+		threads, err := pinned(after, limit, true)
+		reverseList(threads)
+		return threads, err
+	case !at.Before:
+		return regular(after, limit, false)
+	default:
+		threads, err := regular(after, limit, true)
+		if err == nil && fits(threads) {
+			var rest model.Threads
+			rest, err = pinned(nil, remaining(threads), true)
+			threads = append(threads, rest...)
+		}
+		reverseList(threads)
+		return threads, err
 	}
-	return threads, nil
 }
 
-func (u *Usecase) getForumUsers(forum, since string, limit int, desc bool) (model.Users, error) {
-	return u.repo.GetForumUsers(forum, since, limit, desc)
+// getForumThreadsSince serves the legacy since parameter: a creation time, or a thread id for ranked sorts.
+func (u *Usecase) getForumThreadsSince(forum, since, sort, window string, ranked, archived bool, limit int, desc bool) (model.Threads, *model.Page, error) {
+	at := model.Cursor{Sort: sort}
+	if !ranked {
+		threads, err := u.repo.GetForumThreadsSince(forum, since, archived, limit, desc)
+		if err != nil {
+			return nil, nil, err
+		}
+		return threads, u.getThreadsPage(threads, at, true, limit), nil
+	}
+	sinceID, err := strconv.Atoi(since)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: since must be a thread id for sort '%s'", consts.ErrBadRequest, sort)
+	}
+	threads, err := u.repo.GetForumThreadsRanked(forum, sort, window, &sinceID, nil, archived, limit, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	return threads, u.getThreadsPage(threads, at, true, limit), nil
+}
+
+func (u *Usecase) getForumUsers(forum, since, cursor string, limit int, desc bool) (model.Users, *model.Page, error) {
+	at, err := u.getPagePosition(cursor, "", since)
+	if err != nil {
+		return nil, nil, err
+	}
+	users, err := u.repo.GetForumUsers(forum, at.ID, limit, desc != at.Before)
+	if err != nil {
+		return nil, nil, err
+	}
+	if at.Before {
+		reverseList(users)
+	}
+	return users, u.getUsersPage(users, at, at.ID != "", limit), nil
 }
 
 func (u *Usecase) voteForThread(threadSlugOrID string, vote model.VoteDB) (*model.Thread, error) {
@@ -181,12 +508,86 @@ func (u *Usecase) getThread(threadSlugOrID string) (*model.Thread, error) {
 	return u.repo.GetThreadBySlugOrID(threadSlugOrID)
 }
 
-func (u *Usecase) getThreadPosts(threadSlugOrID string, limit int, since *int, sort string, desc bool) (model.Posts, error) {
+func (u *Usecase) getThreadPosts(threadSlugOrID string, limit int, since *int, cursor, sort string, desc bool) (model.Posts, *model.Page, error) {
 	thread, err := u.repo.GetThreadFieldsBySlugOrID("id", threadSlugOrID)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	at, after, err := u.getPageCursor(cursor, sort)
+	if err != nil {
+		return nil, nil, err
+	}
+	if after != nil {
+		since = nil
+	}
+	posts, err := u.repo.GetThreadPosts(thread.ID, limit, since, after, sort, desc != at.Before)
+	if err != nil {
+		return nil, nil, err
+	}
+	paged := after != nil || since != nil
+	if sort != repository.SortParentTree {
+		if at.Before {
+			reverseList(posts)
+		}
+		return posts, u.getPostsPage(posts, at, paged, limit), nil
+	}
+	if at.Before {
+		u.reverseRoots(posts)
+	}
+	return posts, u.getRootsPage(posts, at, paged, limit), nil
+}
+
+func (u *Usecase) getThreadPostsNested(threadSlugOrID string, limit int, since *int, cursor, sort string, desc bool) (model.PostNodes, *model.Page, error) {
+	posts, page, err := u.getThreadPosts(threadSlugOrID, limit, since, cursor, sort, desc)
+	if err != nil {
+		return nil, nil, err
+	}
+	ids := make([]int, 0, len(posts))
+	for _, post := range posts {
+		ids = append(ids, post.ID)
+	}
+	replies, err := u.repo.CountPostsReplies(ids)
+	if err != nil {
+		return nil, nil, err
+	}
+	return model.NestPosts(posts, replies), page, nil
+}
+
+// reverseRoots puts a backwards parent_tree page in order: the roots come back
+// reversed, but the posts under each root must stay in path order.
+func (u *Usecase) reverseRoots(posts model.Posts) {
+	reverseList(posts)
+	for start := 0; start < len(posts); {
+		end := start + 1
+		for end < len(posts) && posts[end].Path.Root() == posts[start].Path.Root() {
+			end++
+		}
+		reverseList(posts[start:end])
+		start = end
 	}
-	return u.repo.GetThreadPosts(thread.ID, limit, since, sort, desc)
+}
+
+func (u *Usecase) getThreadParticipants(threadSlugOrID, cursor string, limit int) ([]*model.Participant, *model.Page, error) {
+	thread, err := u.repo.GetThreadFieldsBySlugOrID("id", threadSlugOrID)
+	if err != nil {
+		return nil, nil, err
+	}
+	at, after, err := u.getPageCursor(cursor, "")
+	if err != nil {
+		return nil, nil, err
+	}
+	participants, err := u.repo.GetThreadParticipants(thread.ID, after, limit, at.Before)
+	if err != nil {
+		return nil, nil, err
+	}
+	if at.Before {
+		reverseList(participants)
+	}
+	rows := make([]model.Cursor, 0, len(participants))
+	for _, participant := range participants {
+		rows = append(rows, model.Cursor{Key: []string{strconv.Itoa(participant.Posts)}, ID: participant.Nickname})
+	}
+	return participants, model.NewPage(at, after != nil, rows, limit), nil
 }
 
 type postDetails struct {
@@ -205,7 +606,9 @@ func (u *Usecase) getPostDetails(id int, related []string) (*postDetails, error)
 	for _, r := range related {
 		switch r {
 		case "user":
-			details.Author, err = u.repo.GetUserByNickname(post.Author)
+			if !post.IsDeleted && post.Author != consts.DeletedUserNickname {
+				details.Author, err = u.repo.GetUserByNickname(post.Author)
+			}
 		case "forum":
 			details.Forum, err = u.repo.GetForumBySlug(post.Forum)
 		case "thread":
@@ -218,8 +621,152 @@ func (u *Usecase) getPostDetails(id int, related []string) (*postDetails, error)
 	return &details, nil
 }
 
-func (u *Usecase) updatePost(id int, message string) (*model.Post, error) {
-	return u.repo.UpdatePostMessage(id, message)
+func (u *Usecase) getPostReplies(id, limit int, since *int, cursor string, depth int, desc bool) (model.Posts, *model.Page, error) {
+	at, since, err := u.getPageIntPosition(cursor, "", since)
+	if err != nil {
+		return nil, nil, err
+	}
+	posts, err := u.repo.GetPostReplies(id, limit, since, depth, desc != at.Before)
+	if err != nil {
+		return nil, nil, err
+	}
+	if at.Before {
+		reverseList(posts)
+	}
+	return posts, u.getPostsPage(posts, at, since != nil, limit), nil
+}
+
+func (u *Usecase) getPostAncestors(id int) (model.Posts, error) {
+	return u.repo.GetPostAncestors(id)
+}
+
+func (u *Usecase) getPostContext(id, count, limit int, sort string) (*model.PostContext, error) {
+	post, err := u.repo.GetPostByID(id)
+	if err != nil {
+		return nil, err
+	}
+	position, err := u.repo.GetThreadPostPosition(post, sort)
+	if err != nil {
+		return nil, err
+	}
+	before, after, err := u.repo.GetThreadPostsAround(post, count, sort)
+	if err != nil {
+		return nil, err
+	}
+	page := 1
+	if limit > 0 {
+		page = (position-1)/limit + 1
+	}
+	return &model.PostContext{
+		Post:     post,
+		Before:   before,
+		After:    after,
+		Position: position,
+		Page:     page,
+	}, nil
+}
+
+func (u *Usecase) updatePost(id int, message, editor string) (*model.Post, error) {
+	if editor != "" {
+		editorNick, err := u.repo.GetUserNickname(editor)
+		if err != nil {
+			return nil, err
+		}
+		editor = editorNick
+	}
+	return u.repo.UpdatePostMessage(id, message, editor)
+}
+
+func (u *Usecase) deletePost(id int) (*model.Post, error) {
+	return u.repo.DeletePost(id)
+}
+
+func (u *Usecase) purgePost(id int) error {
+	return u.repo.PurgePost(id)
+}
+
+func (u *Usecase) getPostRevisions(id int) ([]*model.PostRevision, error) {
+	return u.repo.GetPostRevisions(id)
+}
+
+func (u *Usecase) getPostRevision(id, revision int) (*model.PostRevision, error) {
+	revisions, err := u.repo.GetPostRevisions(id)
+	if err != nil {
+		return nil, err
+	}
+	return u.findPostRevision(revisions, revision)
+}
+
+func (u *Usecase) findPostRevision(revisions []*model.PostRevision, revision int) (*model.PostRevision, error) {
+	if revision < 0 || revision >= len(revisions) {
+		return nil, fmt.Errorf("%w: post has no revision %d", consts.ErrNotFound, revision)
+	}
+	return revisions[revision], nil
+}
+
+func (u *Usecase) getPostDiff(id int, from, to *int) (*model.PostDiff, error) {
+	revisions, err := u.repo.GetPostRevisions(id)
+	if err != nil {
+		return nil, err
+	}
+	toRevision := revisions[len(revisions)-1]
+	if to != nil {
+		if toRevision, err = u.findPostRevision(revisions, *to); err != nil {
+			return nil, err
+		}
+	}
+	fromRevision := revisions[0]
+	if from != nil {
+		if fromRevision, err = u.findPostRevision(revisions, *from); err != nil {
+			return nil, err
+		}
+	} else if toRevision.Revision > 0 {
+		fromRevision = revisions[toRevision.Revision-1]
+	}
+	changes, err := diff.Words(fromRevision.Message, toRevision.Message)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", consts.ErrBadRequest, err)
+	}
+	return &model.PostDiff{
+		Post:    id,
+		From:    fromRevision.Revision,
+		To:      toRevision.Revision,
+		Changes: changes,
+	}, nil
+}
+
+func (u *Usecase) search(filter model.SearchFilter, cursor string, limit int) (*model.SearchResult, error) {
+	if filter.Query == "" {
+		return nil, fmt.Errorf("%w: search query is empty", consts.ErrBadRequest)
+	}
+	if filter.Forum != "" {
+		forum, err := u.repo.GetForumSlug(filter.Forum)
+		if err != nil {
+			return nil, err
+		}
+		filter.Forum = forum.Slug
+	}
+	if filter.Author != "" {
+		author, err := u.repo.GetUserNickname(filter.Author)
+		if err != nil {
+			return nil, err
+		}
+		filter.Author = author
+	}
+	at, after, err := u.getPageCursor(cursor, "")
+	if err != nil {
+		return nil, err
+	}
+	hits, err := u.repo.Search(filter, after, limit)
+	if err != nil {
+		return nil, err
+	}
+	rows := make([]model.Cursor, 0, len(hits))
+	for _, hit := range hits {
+		rank := strconv.FormatFloat(float64(hit.Rank), 'g', -1, 32)
+		rows = append(rows, model.Cursor{Key: []string{rank, hit.Kind}, ID: strconv.Itoa(hit.ID)})
+	}
+	return &model.SearchResult{Hits: hits, Page: *model.NewPage(at, after != nil, rows, limit)}, nil
 }
 
 func (u *Usecase) getStatus() (s model.Status, err error) {
@@ -251,3 +798,82 @@ func (u *Usecase) getStatus() (s model.Status, err error) {
 func (u *Usecase) clear() error {
 	return u.repo.Clear()
 }
+
+// getPagePosition resolves where a list page starts: a cursor wins over the legacy since parameter.
+func (u *Usecase) getPagePosition(cursor, sort, since string) (model.Cursor, error) {
+	if cursor == "" {
+		return model.Cursor{Sort: sort, ID: since}, nil
+	}
+	at, err := model.ParseCursor(cursor)
+	if err != nil {
+		return at, err
+	}
+	if at.Sort != sort {
+		return at, fmt.Errorf("%w: cursor does not match sort '%s'", consts.ErrBadRequest, sort)
+	}
+	return at, nil
+}
+
+// getPageCursor parses the cursor of a list that pages by sort key only, without a since parameter.
+// after is nil on the first page.
+func (u *Usecase) getPageCursor(cursor, sort string) (at model.Cursor, after *model.Cursor, err error) {
+	at, err = u.getPagePosition(cursor, sort, "")
+	if err != nil || cursor == "" {
+		return at, nil, err
+	}
+	return at, &at, nil
+}
+
+func (u *Usecase) getPageIntPosition(cursor, sort string, since *int) (model.Cursor, *int, error) {
+	at, err := u.getPagePosition(cursor, sort, "")
+	if err != nil {
+		return at, nil, err
+	}
+	if cursor == "" {
+		return at, since, nil
+	}
+	id, err := at.IntID()
+	return at, id, err
+}
+
+func (u *Usecase) getUsersPage(users model.Users, at model.Cursor, paged bool, limit int) *model.Page {
+	rows := make([]model.Cursor, 0, len(users))
+	for _, user := range users {
+		rows = append(rows, model.Cursor{ID: user.Nickname})
+	}
+	return model.NewPage(at, paged, rows, limit)
+}
+
+func (u *Usecase) getThreadsPage(threads model.Threads, at model.Cursor, paged bool, limit int) *model.Page {
+	rows := make([]model.Cursor, 0, len(threads))
+	for _, thread := range threads {
+		rows = append(rows, model.ThreadCursor(thread))
+	}
+	return model.NewPage(at, paged, rows, limit)
+}
+
+func (u *Usecase) getPostsPage(posts model.Posts, at model.Cursor, paged bool, limit int) *model.Page {
+	rows := make([]model.Cursor, 0, len(posts))
+	for _, post := range posts {
+		rows = append(rows, model.PostCursor(post))
+	}
+	return model.NewPage(at, paged, rows, limit)
+}
+
+// getRootsPage pages a parent_tree list, where the limit counts root posts
+// and the cursors point at roots rather than at the replies under them.
+func (u *Usecase) getRootsPage(posts model.Posts, at model.Cursor, paged bool, limit int) *model.Page {
+	rows := make([]model.Cursor, 0)
+	for i, post := range posts {
+		if i == 0 || post.Path.Root() != posts[i-1].Path.Root() {
+			rows = append(rows, model.Cursor{ID: strconv.Itoa(post.Path.Root())})
+		}
+	}
+	return model.NewPage(at, paged, rows, limit)
+}
+
+func reverseList[T any](items []T) {
+	for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+		items[i], items[j] = items[j], items[i]
+	}
+}
@@ -0,0 +1,179 @@
+package internal
+
+import (
+	"project/internal/model"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func newTreePosts(paths ...model.PostPath) model.Posts {
+	posts := make(model.Posts, 0, len(paths))
+	for _, path := range paths {
+		posts = append(posts, &model.Post{ID: path[len(path)-1], Path: path})
+	}
+	return posts
+}
+
+func postIDs(posts model.Posts) []int {
+	ids := make([]int, 0, len(posts))
+	for _, post := range posts {
+		ids = append(ids, post.ID)
+	}
+	return ids
+}
+
+func TestReverseRoots(t *testing.T) {
+	u := &Usecase{}
+	tests := []struct {
+		name  string
+		posts model.Posts
+		want  []int
+	}{
+		{name: "empty", posts: model.Posts{}, want: []int{}},
+		{name: "single root", posts: newTreePosts(model.PostPath{1}, model.PostPath{1, 2}), want: []int{1, 2}},
+		{
+			// A backwards page comes back with the roots reversed but each subtree in path order.
+			name: "several roots",
+			posts: newTreePosts(
+				model.PostPath{9}, model.PostPath{9, 11},
+				model.PostPath{5}, model.PostPath{5, 6}, model.PostPath{5, 6, 8},
+				model.PostPath{1},
+			),
+			want: []int{1, 5, 6, 8, 9, 11},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u.reverseRoots(tt.posts)
+			if got := postIDs(tt.posts); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("reverseRoots() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetRootsPage(t *testing.T) {
+	u := &Usecase{}
+	posts := newTreePosts(
+		model.PostPath{1}, model.PostPath{1, 2}, model.PostPath{1, 2, 3},
+		model.PostPath{4}, model.PostPath{4, 5},
+	)
+	at := model.Cursor{Sort: "parent_tree"}
+
+	// Two roots with a limit of two fill the page even though five posts came back.
+	page := u.getRootsPage(posts, at, false, 2)
+	next, err := model.ParseCursor(page.Next)
+	if err != nil {
+		t.Fatalf("next cursor %q: %v", page.Next, err)
+	}
+	if next.ID != "4" || next.Sort != "parent_tree" {
+		t.Errorf("next cursor = %+v, want the last root 4", next)
+	}
+	if page.Prev != "" {
+		t.Errorf("first page has a prev cursor %q", page.Prev)
+	}
+
+	page = u.getRootsPage(posts, at, false, 3)
+	if page.Next != "" {
+		t.Errorf("page with fewer roots than the limit has a next cursor %q", page.Next)
+	}
+
+	page = u.getRootsPage(posts, model.Cursor{Sort: "parent_tree", ID: "9", Before: true}, true, 2)
+	prev, err := model.ParseCursor(page.Prev)
+	if err != nil {
+		t.Fatalf("prev cursor %q: %v", page.Prev, err)
+	}
+	if prev.ID != "1" || !prev.Before {
+		t.Errorf("prev cursor = %+v, want a before cursor at root 1", prev)
+	}
+}
+
+// listThreads serves a threadLister from a fixed list, finding cursor positions by id.
+func listThreads(t *testing.T, threads model.Threads, pinned bool) threadLister {
+	return func(after *model.Cursor, limit int, reverse bool) (model.Threads, error) {
+		start, step := 0, 1
+		if reverse {
+			start, step = len(threads)-1, -1
+		}
+		if after != nil {
+			if after.Pinned != pinned {
+				t.Fatalf("cursor %+v passed to the wrong part of the list", after)
+			}
+			for i, thread := range threads {
+				if strconv.Itoa(thread.ID) == after.ID {
+					start = i + step
+				}
+			}
+		}
+		found := make(model.Threads, 0)
+		for i := start; i >= 0 && i < len(threads) && (limit == 0 || len(found) < limit); i += step {
+			found = append(found, threads[i])
+		}
+		return found, nil
+	}
+}
+
+func TestPageForumThreads(t *testing.T) {
+	u := &Usecase{}
+	pinned := model.Threads{{ID: 1, IsPinned: true}, {ID: 2, IsPinned: true}, {ID: 3, IsPinned: true}}
+	regular := model.Threads{{ID: 10}, {ID: 11}, {ID: 12}}
+	listPinned, listRegular := listThreads(t, pinned, true), listThreads(t, regular, false)
+	const limit = 2
+
+	getPage := func(cursor string) (model.Threads, *model.Page) {
+		at, after, err := u.getPageCursor(cursor, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		threads, err := u.pageForumThreads(listPinned, listRegular, at, after, limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return threads, u.getThreadsPage(threads, at, after != nil, limit)
+	}
+
+	// More pinned threads than fit on a page must still all be reachable.
+	var pages [][]int
+	cursor, last := "", ""
+	for i := 0; i < 10; i++ {
+		threads, page := getPage(cursor)
+		if len(threads) == 0 {
+			break
+		}
+		pages = append(pages, threadIDs(threads))
+		last = cursor
+		if page.Next == "" {
+			break
+		}
+		cursor = page.Next
+	}
+	want := [][]int{{1, 2}, {3, 10}, {11, 12}}
+	if !reflect.DeepEqual(pages, want) {
+		t.Fatalf("forward pages = %v, want %v", pages, want)
+	}
+
+	// Walking back from the last page crosses from regular to pinned threads the same way.
+	_, page := getPage(last)
+	var back [][]int
+	for i := 0; i < 10 && page.Prev != ""; i++ {
+		var threads model.Threads
+		threads, page = getPage(page.Prev)
+		if len(threads) == 0 {
+			break
+		}
+		back = append(back, threadIDs(threads))
+	}
+	wantBack := [][]int{{3, 10}, {1, 2}}
+	if !reflect.DeepEqual(back, wantBack) {
+		t.Errorf("backward pages = %v, want %v", back, wantBack)
+	}
+}
+
+func threadIDs(threads model.Threads) []int {
+	ids := make([]int, 0, len(threads))
+	for _, thread := range threads {
+		ids = append(ids, thread.ID)
+	}
+	return ids
+}